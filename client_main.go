@@ -22,7 +22,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -33,6 +32,7 @@ import (
 
 	"github.com/ebrasha/abdal-gost-proxy/core/colors"
 	"github.com/ebrasha/abdal-gost-proxy/core/display"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
 	"github.com/ebrasha/abdal-gost-proxy/core/models"
 	"github.com/ebrasha/abdal-gost-proxy/core/services/client"
 	"github.com/ebrasha/abdal-gost-proxy/core/term"
@@ -44,16 +44,25 @@ func main() {
 	cfgPath := chooseClientConfig()
 	data, err := os.ReadFile(cfgPath)
 	if err != nil {
-		log.Fatalf("read config %s: %v", cfgPath, err)
+		logger.Fatalf("read config %s: %v", cfgPath, err)
 	}
 	var cfg models.ClientConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
-		log.Fatalf("parse config: %v", err)
+		logger.Fatalf("parse config: %v", err)
 	}
+	if err := logger.Setup(&cfg.Log, "client"); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	defer logger.Close()
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	// Once shutdown starts, a second Ctrl+C skips the drain and exits immediately.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 	if err := client.Run(ctx, &cfg); err != nil && ctx.Err() == nil {
-		log.Fatalf("client: %v", err)
+		logger.Fatalf("client: %v", err)
 	}
 }
 
@@ -77,7 +86,7 @@ func chooseClientConfig() string {
 	dir := filepath.Dir(exePath)
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		log.Fatalf("list config dir %s: %v", dir, err)
+		logger.Fatalf("list config dir %s: %v", dir, err)
 	}
 	var jsonFiles []string
 	for _, e := range entries {
@@ -92,7 +101,7 @@ func chooseClientConfig() string {
 	sort.Strings(jsonFiles)
 	switch len(jsonFiles) {
 	case 0:
-		log.Fatalf("no .json config file found in %s", dir)
+		logger.Fatalf("no .json config file found in %s", dir)
 	case 1:
 		cfgPath := filepath.Join(dir, jsonFiles[0])
 		fmt.Print(colors.Green("Using profile: " + strings.TrimSuffix(jsonFiles[0], filepath.Ext(jsonFiles[0])) + "\n\n"))
@@ -107,11 +116,11 @@ func chooseClientConfig() string {
 	reader := bufio.NewReader(os.Stdin)
 	line, err := reader.ReadString('\n')
 	if err != nil {
-		log.Fatalf("read input: %v", err)
+		logger.Fatalf("read input: %v", err)
 	}
 	choice := strings.TrimSpace(line)
 	if choice == "" {
-		log.Fatalf("no profile selected")
+		logger.Fatalf("no profile selected")
 	}
 	if num, err := strconv.Atoi(choice); err == nil && num >= 1 && num <= len(jsonFiles) {
 		return filepath.Join(dir, jsonFiles[num-1])
@@ -123,6 +132,6 @@ func chooseClientConfig() string {
 			return filepath.Join(dir, name)
 		}
 	}
-	log.Fatalf("invalid profile: %q", choice)
+	logger.Fatalf("invalid profile: %q", choice)
 	return ""
 }
@@ -17,47 +17,80 @@
 
 package colors
 
+import (
+	"os"
+	"sync/atomic"
+)
+
 // ANSI Color codes for neon colors (ANSI256; compatible with Windows 10 CMD/PowerShell when ANSI is enabled).
 const (
 	ColorReset   = "\033[0m"
-	ColorRed     = "\033[38;5;196m"  // Neon Red
-	ColorGreen   = "\033[38;5;46m"   // Neon Green
-	ColorYellow  = "\033[38;5;226m"  // Neon Yellow
-	ColorBlue    = "\033[38;5;51m"   // Neon Blue/Cyan
-	ColorPurple  = "\033[38;5;129m"   // Neon Purple
-	ColorPink    = "\033[38;5;201m"  // Neon Pink
-	ColorOrange  = "\033[38;5;208m"  // Neon Orange
-	ColorWhite   = "\033[38;5;15m"   // Bright White
-	ColorCyan    = "\033[38;5;87m"   // Bright Cyan
-	ColorMagenta = "\033[38;5;165m"  // Bright Magenta
+	ColorRed     = "\033[38;5;196m" // Neon Red
+	ColorGreen   = "\033[38;5;46m"  // Neon Green
+	ColorYellow  = "\033[38;5;226m" // Neon Yellow
+	ColorBlue    = "\033[38;5;51m"  // Neon Blue/Cyan
+	ColorPurple  = "\033[38;5;129m" // Neon Purple
+	ColorPink    = "\033[38;5;201m" // Neon Pink
+	ColorOrange  = "\033[38;5;208m" // Neon Orange
+	ColorWhite   = "\033[38;5;15m"  // Bright White
+	ColorCyan    = "\033[38;5;87m"  // Bright Cyan
+	ColorMagenta = "\033[38;5;165m" // Bright Magenta
 )
 
 // Red returns s wrapped with Neon Red and reset.
-func Red(s string) string { return ColorRed + s + ColorReset }
+func Red(s string) string { return paint(ColorRed, s) }
 
 // Green returns s wrapped with Neon Green and reset.
-func Green(s string) string { return ColorGreen + s + ColorReset }
+func Green(s string) string { return paint(ColorGreen, s) }
 
 // Yellow returns s wrapped with Neon Yellow and reset.
-func Yellow(s string) string { return ColorYellow + s + ColorReset }
+func Yellow(s string) string { return paint(ColorYellow, s) }
 
 // Blue returns s wrapped with Neon Blue/Cyan and reset.
-func Blue(s string) string { return ColorBlue + s + ColorReset }
+func Blue(s string) string { return paint(ColorBlue, s) }
 
 // Purple returns s wrapped with Neon Purple and reset.
-func Purple(s string) string { return ColorPurple + s + ColorReset }
+func Purple(s string) string { return paint(ColorPurple, s) }
 
 // Pink returns s wrapped with Neon Pink and reset.
-func Pink(s string) string { return ColorPink + s + ColorReset }
+func Pink(s string) string { return paint(ColorPink, s) }
 
 // Orange returns s wrapped with Neon Orange and reset.
-func Orange(s string) string { return ColorOrange + s + ColorReset }
+func Orange(s string) string { return paint(ColorOrange, s) }
 
 // White returns s wrapped with Bright White and reset.
-func White(s string) string { return ColorWhite + s + ColorReset }
+func White(s string) string { return paint(ColorWhite, s) }
 
 // Cyan returns s wrapped with Bright Cyan and reset.
-func Cyan(s string) string { return ColorCyan + s + ColorReset }
+func Cyan(s string) string { return paint(ColorCyan, s) }
 
 // Magenta returns s wrapped with Bright Magenta and reset.
-func Magenta(s string) string { return ColorMagenta + s + ColorReset }
+func Magenta(s string) string { return paint(ColorMagenta, s) }
+
+var enabled atomic.Bool
+
+func init() {
+	enabled.Store(detect())
+}
+
+// detect enables colors only on a terminal and when NO_COLOR is not set (https://no-color.org).
+func detect() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// SetEnabled turns colors on or off for every helper.
+func SetEnabled(on bool) { enabled.Store(on) }
+
+// Enabled reports whether the helpers add color codes.
+func Enabled() bool { return enabled.Load() }
+
+func paint(code, s string) string {
+	if !enabled.Load() {
+		return s
+	}
+	return code + s + ColorReset
+}
@@ -0,0 +1,81 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : counting.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 15:47:20
+ * Description : Byte-counting wrappers for the reader/writer of an Xray link.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package conntrack
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+)
+
+// countingReader counts bytes read from the client side of a link (uplink).
+type countingReader struct {
+	buf.Reader
+	n *atomic.Int64
+}
+
+// countingTimeoutReader keeps buf.TimeoutReader visible for outbounds that probe for it.
+type countingTimeoutReader struct {
+	countingReader
+	timeout buf.TimeoutReader
+}
+
+func newCountingReader(r buf.Reader, n *atomic.Int64) buf.Reader {
+	cr := countingReader{Reader: r, n: n}
+	if tr, ok := r.(buf.TimeoutReader); ok {
+		return &countingTimeoutReader{countingReader: cr, timeout: tr}
+	}
+	return &cr
+}
+
+func (r *countingReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	mb, err := r.Reader.ReadMultiBuffer()
+	r.n.Add(int64(mb.Len()))
+	return mb, err
+}
+
+func (r *countingReader) Interrupt() {
+	_ = common.Interrupt(r.Reader)
+}
+
+func (r *countingTimeoutReader) ReadMultiBufferTimeout(d time.Duration) (buf.MultiBuffer, error) {
+	mb, err := r.timeout.ReadMultiBufferTimeout(d)
+	r.n.Add(int64(mb.Len()))
+	return mb, err
+}
+
+// countingWriter counts bytes written back to the client (downlink).
+type countingWriter struct {
+	buf.Writer
+	n *atomic.Int64
+}
+
+func (w *countingWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	w.n.Add(int64(mb.Len()))
+	return w.Writer.WriteMultiBuffer(mb)
+}
+
+func (w *countingWriter) Close() error {
+	return common.Close(w.Writer)
+}
+
+func (w *countingWriter) Interrupt() {
+	_ = common.Interrupt(w.Writer)
+}
@@ -0,0 +1,125 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : throttle.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 20:58:37
+ * Description : Token-bucket shaping of the reader/writer of an Xray link.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package conntrack
+
+import (
+	"context"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"golang.org/x/time/rate"
+)
+
+// Throttle shapes c with the given byte-rate limiters (nil = unlimited). Limiters may be shared by
+// several connections (e.g. all of one user) and changed while they run. Call it from Observer.Opened.
+func (c *Conn) Throttle(up, down *rate.Limiter) {
+	c.upRate, c.downRate = up, down
+}
+
+func (c *Conn) throttleReader(r buf.Reader) buf.Reader {
+	if c.upRate == nil {
+		return r
+	}
+	tr := throttledReader{Reader: r, limit: c.upRate, ctx: c.ctx}
+	if timeout, ok := r.(buf.TimeoutReader); ok {
+		return &throttledTimeoutReader{throttledReader: tr, timeout: timeout}
+	}
+	return &tr
+}
+
+func (c *Conn) throttleWriter(w buf.Writer) buf.Writer {
+	if c.downRate == nil {
+		return w
+	}
+	return &throttledWriter{Writer: w, limit: c.downRate, ctx: c.ctx}
+}
+
+// waitN blocks until limit allows n bytes, in steps of at most the bucket size.
+func waitN(ctx context.Context, limit *rate.Limiter, n int) error {
+	for n > 0 {
+		if limit.Limit() == rate.Inf {
+			return nil
+		}
+		step := n
+		if b := limit.Burst(); step > b && b > 0 {
+			step = b
+		}
+		if err := limit.WaitN(ctx, step); err != nil {
+			return err
+		}
+		n -= step
+	}
+	return nil
+}
+
+// throttledReader delays the data read from the client side of a link (uplink).
+type throttledReader struct {
+	buf.Reader
+	limit *rate.Limiter
+	ctx   context.Context
+}
+
+// throttledTimeoutReader keeps buf.TimeoutReader visible for outbounds that probe for it.
+type throttledTimeoutReader struct {
+	throttledReader
+	timeout buf.TimeoutReader
+}
+
+func (r *throttledReader) wait(mb buf.MultiBuffer, err error) (buf.MultiBuffer, error) {
+	if werr := waitN(r.ctx, r.limit, int(mb.Len())); werr != nil && err == nil {
+		buf.ReleaseMulti(mb)
+		return nil, werr
+	}
+	return mb, err
+}
+
+func (r *throttledReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	return r.wait(r.Reader.ReadMultiBuffer())
+}
+
+func (r *throttledReader) Interrupt() {
+	_ = common.Interrupt(r.Reader)
+}
+
+func (r *throttledTimeoutReader) ReadMultiBufferTimeout(d time.Duration) (buf.MultiBuffer, error) {
+	return r.wait(r.timeout.ReadMultiBufferTimeout(d))
+}
+
+// throttledWriter delays the data written back to the client (downlink).
+type throttledWriter struct {
+	buf.Writer
+	limit *rate.Limiter
+	ctx   context.Context
+}
+
+func (w *throttledWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	if err := waitN(w.ctx, w.limit, int(mb.Len())); err != nil {
+		buf.ReleaseMulti(mb)
+		return err
+	}
+	return w.Writer.WriteMultiBuffer(mb)
+}
+
+func (w *throttledWriter) Close() error {
+	return common.Close(w.Writer)
+}
+
+func (w *throttledWriter) Interrupt() {
+	_ = common.Interrupt(w.Writer)
+}
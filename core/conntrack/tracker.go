@@ -0,0 +1,274 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : tracker.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 15:18:54
+ * Description : Wraps Xray outbound handlers to track every connection dispatched through them.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package conntrack
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/transport"
+	"golang.org/x/time/rate"
+)
+
+// Conn is one connection being dispatched through a tracked outbound.
+type Conn struct {
+	ID       uint64
+	Inbound  string // inbound tag
+	Outbound string // outbound tag
+	Email    string // authenticated user; empty on the client
+	Source   string // client "ip:port"
+	SourceIP string
+	Target   string // destination "host:port"
+	Network  string // "tcp" or "udp"
+	Protocol string // sniffed protocol ("tls", "http", "quic"), may be empty
+	Started  time.Time
+	up       atomic.Int64
+	down     atomic.Int64
+	link     *transport.Link
+	ctx      context.Context // canceled by Close; aborts throttling waits
+	cancel   context.CancelFunc
+	upRate   *rate.Limiter
+	downRate *rate.Limiter
+}
+
+// Uplink returns the bytes sent from the client so far.
+func (c *Conn) Uplink() int64 {
+	return c.up.Load()
+}
+
+// Downlink returns the bytes sent to the client so far.
+func (c *Conn) Downlink() int64 {
+	return c.down.Load()
+}
+
+// Close interrupts both directions; the outbound then finishes the connection.
+func (c *Conn) Close() {
+	c.cancel()
+	_ = common.Interrupt(c.link.Reader)
+	_ = common.Interrupt(c.link.Writer)
+}
+
+// Observer is notified when tracked connections open and close.
+// Observers are asked in registration order; an error from Opened rejects the connection before it
+// reaches the outbound, and every observer (asked or not) then gets Closed with that error.
+type Observer interface {
+	Opened(c *Conn) error
+	Closed(c *Conn, err error)
+}
+
+// RejectError rejects a connection with a short reason used by logs and metrics.
+type RejectError struct {
+	Reason string
+	Msg    string
+}
+
+func (e *RejectError) Error() string {
+	return e.Reason + ": " + e.Msg
+}
+
+// Reject returns a RejectError for Observer.Opened.
+func Reject(reason, format string, args ...interface{}) error {
+	return &RejectError{Reason: reason, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ReasonOf returns the reject reason of err ("rejected" when it is not a RejectError).
+func ReasonOf(err error) string {
+	if re, ok := err.(*RejectError); ok {
+		return re.Reason
+	}
+	return "rejected"
+}
+
+// Tracker keeps the connections of all outbounds it wraps.
+type Tracker struct {
+	conns         map[*Conn]struct{}
+	observers     []Observer
+	nextID        atomic.Uint64
+	disableSplice atomic.Bool
+	mu            sync.Mutex
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{conns: make(map[*Conn]struct{})}
+}
+
+// SetExactBytes turns off XTLS splice copy for new connections so every byte passes the counted link.
+func (t *Tracker) SetExactBytes(on bool) {
+	t.disableSplice.Store(on)
+}
+
+// AddObserver registers o for connections opened from now on.
+func (t *Tracker) AddObserver(o Observer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.observers = append(t.observers, o)
+}
+
+// handler is an outbound.Handler whose Dispatch calls are recorded by a Tracker.
+type handler struct {
+	outbound.Handler
+	tracker *Tracker
+}
+
+// Dispatch blocks for the lifetime of the connection, so the connection is tracked exactly that long.
+func (h *handler) Dispatch(ctx context.Context, link *transport.Link) {
+	t := h.tracker
+	if obs := session.OutboundsFromContext(ctx); len(obs) > 1 {
+		// A hop of a chained outbound (proxySettings / dialerProxy): its connection is already tracked.
+		h.Handler.Dispatch(ctx, link)
+		return
+	}
+	c := NewConn(ctx, h.Tag(), link)
+	c.ID = t.nextID.Add(1)
+	if in := session.InboundFromContext(ctx); in != nil && t.disableSplice.Load() {
+		in.CanSpliceCopy = 3
+	}
+	observers, err := t.open(c)
+	if err != nil {
+		c.Close()
+		return
+	}
+	defer t.close(c, observers)
+	h.Handler.Dispatch(ctx, &transport.Link{
+		Reader: newCountingReader(c.throttleReader(link.Reader), &c.up),
+		Writer: &countingWriter{Writer: c.throttleWriter(link.Writer), n: &c.down},
+	})
+}
+
+// NewConn returns a connection with the session metadata Xray attached to ctx; Close interrupts link.
+// Tracked outbounds create them on Dispatch.
+func NewConn(ctx context.Context, outboundTag string, link *transport.Link) *Conn {
+	c := &Conn{Outbound: outboundTag, Started: time.Now(), link: link}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	if in := session.InboundFromContext(ctx); in != nil {
+		c.Inbound = in.Tag
+		if in.Source.IsValid() {
+			c.Source = in.Source.NetAddr()
+			c.SourceIP = in.Source.Address.String()
+		}
+		if in.User != nil {
+			c.Email = in.User.Email
+		}
+	}
+	if obs := session.OutboundsFromContext(ctx); len(obs) > 0 {
+		ob := obs[len(obs)-1]
+		c.Target = ob.Target.NetAddr()
+		c.Network = ob.Target.Network.SystemString()
+	}
+	if content := session.ContentFromContext(ctx); content != nil {
+		c.Protocol = content.Protocol
+	}
+	return c
+}
+
+// open registers c and asks every observer; the first error rejects it.
+func (t *Tracker) open(c *Conn) ([]Observer, error) {
+	t.mu.Lock()
+	observers := t.observers
+	t.mu.Unlock()
+	for _, o := range observers {
+		if err := o.Opened(c); err != nil {
+			for _, each := range observers {
+				each.Closed(c, err)
+			}
+			return nil, err
+		}
+	}
+	t.mu.Lock()
+	t.conns[c] = struct{}{}
+	t.mu.Unlock()
+	return observers, nil
+}
+
+func (t *Tracker) close(c *Conn, observers []Observer) {
+	c.cancel()
+	t.mu.Lock()
+	delete(t.conns, c)
+	t.mu.Unlock()
+	for _, o := range observers {
+		o.Closed(c, nil)
+	}
+}
+
+// Conns returns a snapshot of the open connections.
+func (t *Tracker) Conns() []*Conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]*Conn, 0, len(t.conns))
+	for c := range t.conns {
+		out = append(out, c)
+	}
+	return out
+}
+
+// Active returns the number of open connections.
+func (t *Tracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.conns)
+}
+
+// CloseAll interrupts every open connection and returns how many there were.
+func (t *Tracker) CloseAll() int {
+	conns := t.Conns()
+	for _, c := range conns {
+		c.Close()
+	}
+	return len(conns)
+}
+
+// CloseWhere interrupts the open connections for which match returns true and returns how many there were.
+func (t *Tracker) CloseWhere(match func(*Conn) bool) int {
+	n := 0
+	for _, c := range t.Conns() {
+		if match(c) {
+			c.Close()
+			n++
+		}
+	}
+	return n
+}
+
+// Wrap replaces the outbound with tag in instance by a tracked one.
+// The first outbound stays the default handler.
+func (t *Tracker) Wrap(instance *core.Instance, tag string) error {
+	om, ok := instance.GetFeature(outbound.ManagerType()).(outbound.Manager)
+	if !ok {
+		return fmt.Errorf("conntrack: outbound manager not found")
+	}
+	h := om.GetHandler(tag)
+	if h == nil {
+		return fmt.Errorf("conntrack: outbound %q not found", tag)
+	}
+	if _, wrapped := h.(*handler); wrapped {
+		return nil
+	}
+	ctx := context.Background()
+	if err := om.RemoveHandler(ctx, tag); err != nil {
+		return err
+	}
+	return om.AddHandler(ctx, &handler{Handler: h, tracker: t})
+}
@@ -0,0 +1,222 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : logger.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 18:12:40
+ * Description : Leveled application logger (text or JSON, console and rotating file) built on log/slog.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/colors"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+var (
+	current atomic.Pointer[slog.Logger]
+	file    io.Closer // rotating file of the current setup, if any
+	mu      sync.Mutex
+)
+
+func init() {
+	current.Store(slog.New(newTextHandler(os.Stdout, "", slog.LevelInfo, true)))
+}
+
+// ParseLevel maps "debug", "info", "warn"/"warning" and "error" to a slog level (empty = info).
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("log level %q: must be debug, info, warn or error", s)
+}
+
+// Check validates cfg without applying it.
+func Check(cfg *models.LogConfig) error {
+	if _, err := ParseLevel(cfg.Level); err != nil {
+		return err
+	}
+	switch cfg.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("log format %q: must be text or json", cfg.Format)
+	}
+	switch strings.ToLower(cfg.XrayLevel) {
+	case "", "debug", "info", "warning", "error", "none":
+	default:
+		return fmt.Errorf("log xray_level %q: must be debug, info, warning, error or none", cfg.XrayLevel)
+	}
+	return nil
+}
+
+// Setup replaces the logger; app ("server" or "client") is shown in every line.
+// Calling it again (e.g. on reload) swaps the outputs and closes the previous log file.
+func Setup(cfg *models.LogConfig, app string) error {
+	if err := Check(cfg); err != nil {
+		return err
+	}
+	level, _ := ParseLevel(cfg.Level)
+	handlers := []slog.Handler{newHandler(cfg.Format, os.Stdout, app, level, colors.Enabled())}
+	var rf *RotatingFile
+	if cfg.File != "" {
+		var err error
+		rf, err = OpenRotatingFile(cfg.File, RotateOptionsOf(cfg))
+		if err != nil {
+			return err
+		}
+		handlers = append(handlers, newHandler(cfg.Format, rf, app, level, false))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current.Store(slog.New(fanout(handlers)))
+	if file != nil {
+		_ = file.Close()
+		file = nil
+	}
+	if rf != nil {
+		file = rf
+	}
+	return nil
+}
+
+// RotateOptionsOf returns the rotation settings of cfg.
+func RotateOptionsOf(cfg *models.LogConfig) RotateOptions {
+	return RotateOptions{
+		MaxSize:    int64(cfg.MaxSizeMB) << 20,
+		Interval:   time.Duration(cfg.RotateHours) * time.Hour,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     time.Duration(cfg.MaxAgeDays) * 24 * time.Hour,
+	}
+}
+
+// NewFileLogger returns a logger that writes only to a rotating file at path (e.g. an access log).
+// format is "text" or "json"; the caller closes the returned file.
+func NewFileLogger(path, format, app string, opts RotateOptions) (*slog.Logger, io.Closer, error) {
+	rf, err := OpenRotatingFile(path, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return slog.New(newHandler(format, rf, app, slog.LevelDebug, false)), rf, nil
+}
+
+// Close closes the log file, if any; later lines go to the console only.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+func newHandler(format string, w io.Writer, app string, level slog.Level, color bool) slog.Handler {
+	if format == "json" {
+		h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+		if app == "" {
+			return h
+		}
+		return h.WithAttrs([]slog.Attr{slog.String("app", app)})
+	}
+	return newTextHandler(w, app, level, color)
+}
+
+// L returns the current logger for structured logging with attributes.
+func L() *slog.Logger {
+	return current.Load()
+}
+
+func logf(level slog.Level, format string, args ...interface{}) {
+	l := current.Load()
+	if !l.Enabled(context.Background(), level) {
+		return
+	}
+	l.Log(context.Background(), level, fmt.Sprintf(format, args...))
+}
+
+// Debugf logs at debug level.
+func Debugf(format string, args ...interface{}) { logf(slog.LevelDebug, format, args...) }
+
+// Infof logs at info level.
+func Infof(format string, args ...interface{}) { logf(slog.LevelInfo, format, args...) }
+
+// Warnf logs at warn level.
+func Warnf(format string, args ...interface{}) { logf(slog.LevelWarn, format, args...) }
+
+// Errorf logs at error level.
+func Errorf(format string, args ...interface{}) { logf(slog.LevelError, format, args...) }
+
+// Fatalf logs at error level, closes the log file and exits with status 1.
+func Fatalf(format string, args ...interface{}) {
+	logf(slog.LevelError, format, args...)
+	_ = Close()
+	os.Exit(1)
+}
+
+// fanout sends every record to all handlers that accept its level.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var first error
+	for _, h := range f {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,178 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : rotate.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 18:39:05
+ * Description : Log file writer with size/time-based rotation and retention of rotated files.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSize is the rotation size when RotateOptions.MaxSize is 0.
+const DefaultMaxSize = 100 << 20
+
+// backupTimeFormat is inserted between the file name and extension of rotated files.
+const backupTimeFormat = "20060102-150405"
+
+// RotateOptions controls when a RotatingFile rotates and which rotated files it keeps.
+type RotateOptions struct {
+	MaxSize    int64         // bytes; default DefaultMaxSize
+	Interval   time.Duration // also rotate after this long (0 = size only)
+	MaxBackups int           // rotated files to keep (0 = all)
+	MaxAge     time.Duration // delete rotated files older than this (0 = never)
+}
+
+// RotatingFile appends to path and renames it to "name-<time>.ext" when it gets too big or too old.
+type RotatingFile struct {
+	path   string
+	opts   RotateOptions
+	f      *os.File
+	size   int64
+	opened time.Time
+	mu     sync.Mutex
+}
+
+// OpenRotatingFile opens (or creates) path for appending.
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	r := &RotatingFile{path: path, opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	if dir := filepath.Dir(r.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("log file: %v", err)
+		}
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("log file: %v", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("log file: %v", err)
+	}
+	r.f, r.size, r.opened = f, fi.Size(), time.Now()
+	return nil
+}
+
+// Write appends p, rotating first when p would exceed the size limit or the interval has passed.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	tooBig := r.size > 0 && r.size+int64(len(p)) > r.opts.MaxSize
+	tooOld := r.opts.Interval > 0 && time.Since(r.opened) >= r.opts.Interval
+	if tooBig || tooOld {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate closes the file, renames it with a timestamp, reopens path and prunes old backups.
+func (r *RotatingFile) rotate() error {
+	_ = r.f.Close()
+	r.f = nil
+	if err := os.Rename(r.path, r.backupName(time.Now())); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("log rotate: %v", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+func (r *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(r.path, ext)
+	name := base + "-" + t.Format(backupTimeFormat) + ext
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%s.%d%s", base, t.Format(backupTimeFormat), i, ext)
+	}
+}
+
+// prune removes rotated files beyond MaxBackups or older than MaxAge (newest are kept).
+func (r *RotatingFile) prune() {
+	if r.opts.MaxBackups <= 0 && r.opts.MaxAge <= 0 {
+		return
+	}
+	ext := filepath.Ext(r.path)
+	matches, err := filepath.Glob(strings.TrimSuffix(r.path, ext) + "-*" + ext)
+	if err != nil {
+		return
+	}
+	type backup struct {
+		path string
+		mod  time.Time
+	}
+	prefix := strings.TrimSuffix(r.path, ext) + "-"
+	var backups []backup
+	for _, m := range matches {
+		stamp := strings.TrimPrefix(m, prefix)
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, stamp[:len(backupTimeFormat)]); err != nil {
+			continue // not one of ours
+		}
+		if fi, err := os.Stat(m); err == nil && fi.Mode().IsRegular() {
+			backups = append(backups, backup{m, fi.ModTime()})
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].mod.After(backups[j].mod) })
+	for i, b := range backups {
+		expired := r.opts.MaxAge > 0 && time.Since(b.mod) > r.opts.MaxAge
+		extra := r.opts.MaxBackups > 0 && i >= r.opts.MaxBackups
+		if expired || extra {
+			_ = os.Remove(b.path)
+		}
+	}
+}
+
+// Close closes the file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
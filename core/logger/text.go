@@ -0,0 +1,124 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : text.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 18:26:17
+ * Description : Human-readable slog handler: time, level, app prefix, message and key=value attributes.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/colors"
+)
+
+// textHandler writes "2006-01-02 15:04:05 INFO  [Abdal Gost Proxy server] message key=value".
+type textHandler struct {
+	w      io.Writer
+	prefix string
+	level  slog.Level
+	color  bool
+	attrs  string // pre-rendered " key=value" pairs from WithAttrs
+	group  string // key prefix from WithGroup
+	mu     *sync.Mutex
+}
+
+func newTextHandler(w io.Writer, app string, level slog.Level, color bool) *textHandler {
+	prefix := "[Abdal Gost Proxy]"
+	if app != "" {
+		prefix = "[Abdal Gost Proxy " + app + "]"
+	}
+	return &textHandler{w: w, prefix: prefix, level: level, color: color, mu: &sync.Mutex{}}
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if !r.Time.IsZero() {
+		b.WriteString(r.Time.Format("2006-01-02 15:04:05") + " ")
+	}
+	level := r.Level.String()
+	b.WriteString(level + strings.Repeat(" ", 6-len(level)))
+	b.WriteString(h.prefix + " " + r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.group, a)
+		return true
+	})
+	line := b.String()
+	if h.color {
+		line = paintLevel(r.Level, line)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line+"\n")
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	var b strings.Builder
+	for _, a := range attrs {
+		writeAttr(&b, h.group, a)
+	}
+	c.attrs += b.String()
+	return &c
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.group += name + "."
+	return &c
+}
+
+func writeAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeAttr(b, group+a.Key+".", ga)
+		}
+		return
+	}
+	v := a.Value.String()
+	if v == "" || strings.ContainsAny(v, " \t\"=") {
+		v = strconv.Quote(v)
+	}
+	b.WriteString(" " + group + a.Key + "=" + v)
+}
+
+func paintLevel(level slog.Level, s string) string {
+	switch {
+	case level >= slog.LevelError:
+		return colors.Red(s)
+	case level >= slog.LevelWarn:
+		return colors.Yellow(s)
+	case level >= slog.LevelInfo:
+		return colors.Cyan(s)
+	}
+	return colors.White(s)
+}
@@ -0,0 +1,91 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : xray.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 18:51:33
+ * Description : Routes Xray's console error and access logs through the application logger.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	applog "github.com/xtls/xray-core/app/log"
+	"github.com/xtls/xray-core/common"
+	xlog "github.com/xtls/xray-core/common/log"
+)
+
+// XrayLogConfig returns the "loglevel" and "access" values for the Xray JSON "log" section.
+// Both logs use the console type, which this package takes over.
+func XrayLogConfig(cfg *models.LogConfig) (level, access string) {
+	level = strings.ToLower(cfg.XrayLevel)
+	if level == "" {
+		level = "warning"
+	}
+	if cfg.DisableXrayAccess {
+		access = "none"
+	}
+	return level, access
+}
+
+func init() {
+	common.Must(applog.RegisterHandlerCreator(applog.LogType_Console, func(applog.LogType, applog.HandlerCreatorOptions) (xlog.Handler, error) {
+		return xrayHandler{}, nil
+	}))
+}
+
+// xrayHandler forwards Xray messages (already filtered by Xray's own level) to the current logger.
+type xrayHandler struct{}
+
+func (xrayHandler) Handle(msg xlog.Message) {
+	l := current.Load().With(slog.String("component", "xray"))
+	ctx := context.Background()
+	switch m := msg.(type) {
+	case *xlog.GeneralMessage:
+		l.Log(ctx, xraySeverity(m.Severity), fmt.Sprint(m.Content))
+	case *xlog.AccessMessage:
+		attrs := []slog.Attr{
+			slog.String("from", fmt.Sprint(m.From)),
+			slog.String("to", fmt.Sprint(m.To)),
+			slog.String("status", string(m.Status)),
+		}
+		if m.Detour != "" {
+			attrs = append(attrs, slog.String("route", m.Detour))
+		}
+		if m.Email != "" {
+			attrs = append(attrs, slog.String("email", m.Email))
+		}
+		if reason := fmt.Sprint(m.Reason); m.Reason != nil && reason != "" {
+			attrs = append(attrs, slog.String("reason", reason))
+		}
+		l.LogAttrs(ctx, slog.LevelInfo, "access", attrs...)
+	default:
+		l.Info(msg.String())
+	}
+}
+
+func xraySeverity(s xlog.Severity) slog.Level {
+	switch s {
+	case xlog.Severity_Debug:
+		return slog.LevelDebug
+	case xlog.Severity_Warning:
+		return slog.LevelWarn
+	case xlog.Severity_Error:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
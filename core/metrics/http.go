@@ -0,0 +1,78 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : http.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 16:28:41
+ * Description : Serves /metrics in Prometheus text format on a loopback listener.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/netutil"
+)
+
+// Server exposes a registry on /metrics.
+type Server struct {
+	ln  net.Listener
+	srv *http.Server
+	mu  sync.Mutex
+}
+
+// CheckListen rejects metrics listen addresses that are not on loopback.
+func CheckListen(addr string) error {
+	if err := netutil.CheckLoopback(addr); err != nil {
+		return fmt.Errorf("metrics.listen %v", err)
+	}
+	return nil
+}
+
+// NewServer starts serving reg on ln (GET /metrics).
+func NewServer(ln net.Listener, reg *Registry) *Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = reg.WritePrometheus(w)
+	})
+	s := &Server{ln: ln, srv: &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}}
+	go func() { _ = s.srv.Serve(ln) }()
+	return s
+}
+
+// Addr returns the listen address.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// File duplicates the listening socket (upgrade handover).
+func (s *Server) File() (*os.File, error) {
+	return netutil.ListenerFile(s.ln)
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.srv == nil {
+		return nil
+	}
+	err := s.srv.Close()
+	s.srv = nil
+	return err
+}
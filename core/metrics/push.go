@@ -0,0 +1,202 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : push.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 17:36:08
+ * Description : Periodic UDP push of registry metrics in StatsD or InfluxDB line protocol.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Push formats.
+const (
+	FormatStatsD = "statsd"
+	FormatInflux = "influx"
+)
+
+// maxDatagram keeps push packets below a typical path MTU.
+const maxDatagram = 1400
+
+// PushOptions configures a Pusher.
+type PushOptions struct {
+	Address  string        // UDP "host:port"
+	Format   string        // FormatStatsD (default) or FormatInflux
+	Interval time.Duration // default 10s
+	Prefix   string        // only families whose name starts with Prefix are pushed
+}
+
+// Pusher sends the registry to a UDP collector every interval until closed.
+type Pusher struct {
+	reg  *Registry
+	opts PushOptions
+	conn net.Conn
+	last map[string]float64 // StatsD: counter values of the previous push, to send deltas
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// CheckPush validates a push address and format.
+func CheckPush(addr, format string) error {
+	if _, err := net.ResolveUDPAddr("udp", addr); err != nil {
+		return fmt.Errorf("metrics.push.address %q: %v", addr, err)
+	}
+	switch format {
+	case "", FormatStatsD, FormatInflux:
+		return nil
+	}
+	return fmt.Errorf("metrics.push.format %q: must be %q or %q", format, FormatStatsD, FormatInflux)
+}
+
+// NewPusher dials the collector and starts pushing.
+func NewPusher(reg *Registry, opts PushOptions) (*Pusher, error) {
+	if err := CheckPush(opts.Address, opts.Format); err != nil {
+		return nil, err
+	}
+	if opts.Format == "" {
+		opts.Format = FormatStatsD
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	conn, err := net.Dial("udp", opts.Address)
+	if err != nil {
+		return nil, fmt.Errorf("metrics push: %v", err)
+	}
+	p := &Pusher{reg: reg, opts: opts, conn: conn, last: make(map[string]float64), stop: make(chan struct{}), done: make(chan struct{})}
+	go p.loop()
+	return p, nil
+}
+
+func (p *Pusher) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			p.push()
+			return
+		case <-ticker.C:
+			p.push()
+		}
+	}
+}
+
+// Close sends a final push and stops the pusher.
+func (p *Pusher) Close() error {
+	p.once.Do(func() { close(p.stop) })
+	<-p.done
+	return p.conn.Close()
+}
+
+// push formats the selected families and writes them in datagrams of at most maxDatagram bytes.
+func (p *Pusher) push() {
+	var lines []string
+	now := time.Now()
+	for _, f := range p.reg.Gather() {
+		if !strings.HasPrefix(f.Name, p.opts.Prefix) {
+			continue
+		}
+		if p.opts.Format == FormatInflux {
+			lines = append(lines, influxLines(f, now)...)
+		} else {
+			lines = append(lines, p.statsdLines(f)...)
+		}
+	}
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > maxDatagram {
+			_, _ = p.conn.Write(packet)
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		_, _ = p.conn.Write(packet)
+	}
+}
+
+// statsdLines writes gauges as "|g" and counters as "|c" deltas since the previous push.
+// Labels become name segments (name.label.value); histogram buckets are skipped.
+func (p *Pusher) statsdLines(f Family) []string {
+	var out []string
+	for _, s := range f.Samples {
+		if strings.HasSuffix(s.Name, "_bucket") && f.Type == "histogram" {
+			continue
+		}
+		name := s.Name
+		for _, l := range s.Labels {
+			name += "." + statsdSegment(l.Name) + "." + statsdSegment(l.Value)
+		}
+		if f.Type == "gauge" {
+			out = append(out, name+":"+formatValue(s.Value)+"|g")
+			continue
+		}
+		delta := s.Value - p.last[name]
+		if delta < 0 { // counter reset (e.g. the collector restarted)
+			delta = s.Value
+		}
+		p.last[name] = s.Value
+		if delta > 0 {
+			out = append(out, name+":"+formatValue(delta)+"|c")
+		}
+	}
+	return out
+}
+
+// statsdSegment replaces characters that StatsD uses as separators.
+func statsdSegment(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ':', '|', '@', '#', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// influxLines writes one point per sample: measurement,label=value value=<v> <unix ns>.
+func influxLines(f Family, now time.Time) []string {
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	out := make([]string, 0, len(f.Samples))
+	for _, s := range f.Samples {
+		line := influxEscape(s.Name)
+		for _, l := range s.Labels {
+			if l.Value == "" {
+				continue // empty tag values are invalid in line protocol
+			}
+			line += "," + influxEscape(l.Name) + "=" + influxEscape(l.Value)
+		}
+		out = append(out, line+" value="+strconv.FormatFloat(s.Value, 'f', -1, 64)+" "+ts)
+	}
+	return out
+}
+
+func influxEscape(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`).Replace(s)
+}
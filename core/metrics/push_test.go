@@ -0,0 +1,132 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : push_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 04:05:12
+ * Description : Tests for the StatsD and InfluxDB line protocol pusher against a local UDP collector.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package metrics
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenCollector returns a local UDP socket standing in for the collector.
+func listenCollector(t *testing.T) net.PacketConn {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc
+}
+
+// readLines reads one datagram and splits it into lines.
+func readLines(t *testing.T, pc net.PacketConn) []string {
+	t.Helper()
+	buf := make([]byte, 64*1024)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read push: %v", err)
+	}
+	if n > maxDatagram {
+		t.Errorf("datagram of %d bytes, want at most %d", n, maxDatagram)
+	}
+	return strings.Split(string(buf[:n]), "\n")
+}
+
+func TestPushStatsD(t *testing.T) {
+	pc := listenCollector(t)
+	reg := NewRegistry()
+	bytes := reg.NewCounterVec("abdal_server_bytes_total", "Bytes.", "user")
+	active := reg.NewGaugeVec("abdal_server_active", "Active.")
+	other := reg.NewGaugeVec("other_gauge", "Not pushed.")
+	bytes.Add(5, "a.b@x")
+	active.Set(3)
+	other.Set(1)
+
+	p, err := NewPusher(reg, PushOptions{Address: pc.LocalAddr().String(), Interval: time.Hour, Prefix: "abdal_server_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.push()
+	got := readLines(t, pc)
+	want := []string{"abdal_server_bytes_total.user.a_b_x:5|c", "abdal_server_active:3|g"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("first push:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Counters are sent as deltas; an unchanged counter is left out.
+	bytes.Add(2, "a.b@x")
+	p.push()
+	if got := readLines(t, pc); strings.Join(got, "\n") != "abdal_server_bytes_total.user.a_b_x:2|c\nabdal_server_active:3|g" {
+		t.Errorf("second push: %q", got)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLines(t, pc); strings.Join(got, "\n") != "abdal_server_active:3|g" {
+		t.Errorf("final push on close: %q", got)
+	}
+}
+
+func TestPushInflux(t *testing.T) {
+	pc := listenCollector(t)
+	reg := NewRegistry()
+	reg.NewGaugeVec("abdal_server_active", "Active.", "inbound", "empty").Set(4, "vless in", "")
+	reg.NewCounterVec("abdal_server_rejected_total", "Rejected.").Add(7)
+
+	p, err := NewPusher(reg, PushOptions{Address: pc.LocalAddr().String(), Format: FormatInflux, Interval: time.Hour, Prefix: "abdal_server_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	before := time.Now().UnixNano()
+	p.push()
+	got := readLines(t, pc)
+	want := []string{`abdal_server_active,inbound=vless\ in value=4`, "abdal_server_rejected_total value=7"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %d lines", got, len(want))
+	}
+	for i, line := range got {
+		point, ts, ok := strings.Cut(line, " value=")
+		if !ok {
+			t.Fatalf("line %q: no value field", line)
+		}
+		value, stamp, _ := strings.Cut(ts, " ")
+		if point+" value="+value != want[i] {
+			t.Errorf("line %d = %q, want prefix %q", i, line, want[i])
+		}
+		if n, err := strconv.ParseInt(stamp, 10, 64); err != nil || n < before {
+			t.Errorf("line %q: timestamp is not the push time in ns", line)
+		}
+	}
+}
+
+func TestCheckPush(t *testing.T) {
+	if err := CheckPush("127.0.0.1:8125", ""); err != nil {
+		t.Errorf("default format: %v", err)
+	}
+	if err := CheckPush("127.0.0.1:8125", "graphite"); err == nil {
+		t.Error("unknown format accepted")
+	}
+	if err := CheckPush("no-port", FormatStatsD); err == nil {
+		t.Error("address without port accepted")
+	}
+}
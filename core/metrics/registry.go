@@ -0,0 +1,318 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : registry.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 16:02:37
+ * Description : Minimal metrics registry (counters, gauges, histograms) with Prometheus text exposition.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Label is one metric label.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one value of a metric family (histograms use the _bucket/_sum/_count suffixes in Name).
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a named metric with its samples, as written to /metrics.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string // "counter", "gauge" or "histogram"
+	Samples []Sample
+}
+
+// Collector produces metric families at scrape time.
+type Collector interface {
+	Collect() []Family
+}
+
+// CollectorFunc adapts a function to Collector.
+type CollectorFunc func() []Family
+
+// Collect implements Collector.
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
+
+// Registry holds collectors in registration order.
+type Registry struct {
+	collectors []Collector
+	mu         sync.Mutex
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds c to the registry.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Gather collects every family.
+func (r *Registry) Gather() []Family {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+	var out []Family
+	for _, c := range collectors {
+		out = append(out, c.Collect()...)
+	}
+	return out
+}
+
+// WritePrometheus writes every family in the Prometheus text format (version 0.0.4).
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range r.Gather() {
+		bw.WriteString("# HELP " + f.Name + " " + escapeHelp(f.Help) + "\n")
+		bw.WriteString("# TYPE " + f.Name + " " + f.Type + "\n")
+		for _, s := range f.Samples {
+			bw.WriteString(s.Name)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(l.Name + `="` + escapeLabel(l.Value) + `"`)
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatValue(s.Value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// labelKey joins label values into a map key.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// makeLabels pairs names with values.
+func makeLabels(names, values []string) []Label {
+	out := make([]Label, len(names))
+	for i, n := range names {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		out[i] = Label{Name: n, Value: v}
+	}
+	return out
+}
+
+// vec is the shared part of counter and gauge vectors.
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	values map[string]*series
+	mu     sync.Mutex
+}
+
+type series struct {
+	labels []string
+	value  float64
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]*series)}
+}
+
+func (v *vec) update(labelValues []string, fn func(*series)) {
+	key := labelKey(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.values[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), labelValues...)}
+		v.values[key] = s
+	}
+	fn(s)
+}
+
+// Collect implements Collector (series sorted by labels for stable output).
+func (v *vec) Collect() []Family {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	f := Family{Name: v.name, Help: v.help, Type: v.kind}
+	for _, k := range keys {
+		s := v.values[k]
+		f.Samples = append(f.Samples, Sample{Name: v.name, Labels: makeLabels(v.labels, s.labels), Value: s.value})
+	}
+	return []Family{f}
+}
+
+// CounterVec is a monotonically increasing value per label set.
+type CounterVec struct{ *vec }
+
+// NewCounterVec creates and registers a counter.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labels)}
+	r.Register(c)
+	return c
+}
+
+// Add increases the counter for the label values by delta (negative deltas are ignored).
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.update(labelValues, func(s *series) { s.value += delta })
+}
+
+// Inc increases the counter for the label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// GaugeVec is a value per label set that can go up and down.
+type GaugeVec struct{ *vec }
+
+// NewGaugeVec creates and registers a gauge.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, "gauge", labels)}
+	r.Register(g)
+	return g
+}
+
+// Set sets the gauge for the label values.
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.update(labelValues, func(s *series) { s.value = value })
+}
+
+// Add changes the gauge for the label values by delta.
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.update(labelValues, func(s *series) { s.value += delta })
+}
+
+// DefaultLatencyBuckets are histogram upper bounds in seconds for network latencies.
+var DefaultLatencyBuckets = []float64{0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// HistogramVec counts observations into cumulative buckets per label set.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	values  map[string]*histogram
+	mu      sync.Mutex
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates and registers a histogram with the given bucket upper bounds.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogram)}
+	r.Register(h)
+	return h
+}
+
+// Observe records one value for the label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogram{labels: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	for i, b := range h.buckets {
+		if value <= b {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// Collect implements Collector.
+func (h *HistogramVec) Collect() []Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	f := Family{Name: h.name, Help: h.help, Type: "histogram"}
+	for _, k := range keys {
+		s := h.values[k]
+		labels := makeLabels(h.labels, s.labels)
+		for i, b := range h.buckets {
+			le := append(append([]Label(nil), labels...), Label{Name: "le", Value: formatValue(b)})
+			f.Samples = append(f.Samples, Sample{Name: h.name + "_bucket", Labels: le, Value: float64(s.counts[i])})
+		}
+		inf := append(append([]Label(nil), labels...), Label{Name: "le", Value: "+Inf"})
+		f.Samples = append(f.Samples,
+			Sample{Name: h.name + "_bucket", Labels: inf, Value: float64(s.count)},
+			Sample{Name: h.name + "_sum", Labels: labels, Value: s.sum},
+			Sample{Name: h.name + "_count", Labels: labels, Value: float64(s.count)},
+		)
+	}
+	return []Family{f}
+}
@@ -0,0 +1,72 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : runtime.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 16:20:05
+ * Description : Go runtime statistics and build info metrics.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package metrics
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	xraycore "github.com/xtls/xray-core/core"
+)
+
+// RegisterRuntime adds Go runtime statistics (goroutines, memory, GC) and the process start time.
+func (r *Registry) RegisterRuntime() {
+	start := float64(time.Now().Unix())
+	r.Register(CollectorFunc(func() []Family {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		gauge := func(name, help string, v float64) Family {
+			return Family{Name: name, Help: help, Type: "gauge", Samples: []Sample{{Name: name, Value: v}}}
+		}
+		counter := func(name, help string, v float64) Family {
+			return Family{Name: name, Help: help, Type: "counter", Samples: []Sample{{Name: name, Value: v}}}
+		}
+		return []Family{
+			gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())),
+			gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(ms.Alloc)),
+			gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(ms.HeapInuse)),
+			gauge("go_memstats_sys_bytes", "Number of bytes obtained from the system.", float64(ms.Sys)),
+			counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(ms.NumGC)),
+			counter("go_gc_pause_seconds_total", "Total GC stop-the-world pause time in seconds.", float64(ms.PauseTotalNs)/1e9),
+			gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", start),
+		}
+	}))
+}
+
+// RegisterBuildInfo adds abdal_build_info{app,version,go_version,xray_version} = 1.
+func (r *Registry) RegisterBuildInfo(app string) {
+	version := "dev"
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		version = bi.Main.Version
+	}
+	labels := []Label{
+		{Name: "app", Value: app},
+		{Name: "version", Value: version},
+		{Name: "go_version", Value: runtime.Version()},
+		{Name: "xray_version", Value: xraycore.Version()},
+	}
+	r.Register(CollectorFunc(func() []Family {
+		return []Family{{
+			Name:    "abdal_build_info",
+			Help:    "Build information of the running binary.",
+			Type:    "gauge",
+			Samples: []Sample{{Name: "abdal_build_info", Labels: labels, Value: 1}},
+		}}
+	}))
+}
@@ -0,0 +1,165 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : traffic.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 16:41:13
+ * Description : Connection and traffic metrics built from tracked Xray connections.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+)
+
+// TrafficCollector observes tracked connections and exports bytes, active and total connections.
+// Byte counters include connections that are still open, so long-lived tunnels show up live.
+type TrafficCollector struct {
+	prefix    string
+	byUser    bool
+	live      map[*conntrack.Conn]struct{}
+	userBytes map[string]*[2]int64 // closed connections: [up, down]
+	outBytes  map[string]*[2]int64
+	accepted  map[string]float64 // by inbound
+	rejected  map[string]float64 // by reason
+	mu        sync.Mutex
+}
+
+// NewTrafficCollector creates and registers a collector; metric names start with prefix (e.g. "abdal_server").
+// byUser adds per-user byte and active connection series. Add it as the last tracker observer so it only
+// counts connections every other observer accepted.
+func (r *Registry) NewTrafficCollector(prefix string, byUser bool) *TrafficCollector {
+	t := &TrafficCollector{
+		prefix:    prefix,
+		byUser:    byUser,
+		live:      make(map[*conntrack.Conn]struct{}),
+		userBytes: make(map[string]*[2]int64),
+		outBytes:  make(map[string]*[2]int64),
+		accepted:  make(map[string]float64),
+		rejected:  make(map[string]float64),
+	}
+	r.Register(t)
+	return t
+}
+
+// Opened implements conntrack.Observer.
+func (t *TrafficCollector) Opened(c *conntrack.Conn) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.live[c] = struct{}{}
+	t.accepted[c.Inbound]++
+	return nil
+}
+
+// Closed implements conntrack.Observer; err is set when an observer rejected the connection.
+func (t *TrafficCollector) Closed(c *conntrack.Conn, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.rejected[conntrack.ReasonOf(err)]++
+		return
+	}
+	delete(t.live, c)
+	addBytes(t.userBytes, c.Email, c.Uplink(), c.Downlink())
+	addBytes(t.outBytes, c.Outbound, c.Uplink(), c.Downlink())
+}
+
+// Rejected counts a connection refused before it was tracked (e.g. by the PROXY protocol gate).
+func (t *TrafficCollector) Rejected(reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rejected[reason]++
+}
+
+func addBytes(m map[string]*[2]int64, key string, up, down int64) {
+	v, ok := m[key]
+	if !ok {
+		v = &[2]int64{}
+		m[key] = v
+	}
+	v[0] += up
+	v[1] += down
+}
+
+// Collect implements Collector.
+func (t *TrafficCollector) Collect() []Family {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	userBytes := copyBytes(t.userBytes)
+	outBytes := copyBytes(t.outBytes)
+	active := make(map[string]float64)
+	for c := range t.live {
+		addBytes(userBytes, c.Email, c.Uplink(), c.Downlink())
+		addBytes(outBytes, c.Outbound, c.Uplink(), c.Downlink())
+		if t.byUser {
+			active[c.Email]++
+		} else {
+			active[c.Outbound]++
+		}
+	}
+
+	var out []Family
+	if t.byUser {
+		out = append(out, bytesFamily(t.prefix+"_user_bytes_total", "Bytes transferred per user and direction.", "user", userBytes))
+	}
+	out = append(out, bytesFamily(t.prefix+"_outbound_bytes_total", "Bytes transferred per outbound and direction.", "outbound", outBytes))
+	activeLabel, activeHelp := "outbound", "Open connections per outbound."
+	if t.byUser {
+		activeLabel, activeHelp = "user", "Open connections per user."
+	}
+	out = append(out,
+		simpleFamily(t.prefix+"_active_connections", activeHelp, "gauge", activeLabel, active),
+		simpleFamily(t.prefix+"_connections_total", "Accepted connections per inbound.", "counter", "inbound", t.accepted),
+		simpleFamily(t.prefix+"_connections_rejected_total", "Rejected connections per reason.", "counter", "reason", t.rejected),
+	)
+	return out
+}
+
+func copyBytes(m map[string]*[2]int64) map[string]*[2]int64 {
+	out := make(map[string]*[2]int64, len(m))
+	for k, v := range m {
+		c := *v
+		out[k] = &c
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func bytesFamily(name, help, label string, m map[string]*[2]int64) Family {
+	f := Family{Name: name, Help: help, Type: "counter"}
+	for _, k := range sortedKeys(m) {
+		f.Samples = append(f.Samples,
+			Sample{Name: name, Labels: []Label{{Name: label, Value: k}, {Name: "direction", Value: "up"}}, Value: float64(m[k][0])},
+			Sample{Name: name, Labels: []Label{{Name: label, Value: k}, {Name: "direction", Value: "down"}}, Value: float64(m[k][1])},
+		)
+	}
+	return f
+}
+
+func simpleFamily(name, help, kind, label string, m map[string]float64) Family {
+	f := Family{Name: name, Help: help, Type: kind}
+	for _, k := range sortedKeys(m) {
+		f.Samples = append(f.Samples, Sample{Name: name, Labels: []Label{{Name: label, Value: k}}, Value: m[k]})
+	}
+	return f
+}
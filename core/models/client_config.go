@@ -19,24 +19,44 @@ package models
 
 // HealthCheckConfig holds periodic health check and stability options.
 type HealthCheckConfig struct {
-	Enabled        bool   `json:"enabled"`
-	IntervalSeconds int   `json:"interval_seconds"`
-	TimeoutSeconds  int   `json:"timeout_seconds"`
-	MaxRetries     int   `json:"max_retries"`
-	CheckURL       string `json:"check_url"`
+	Enabled         bool   `json:"enabled"`
+	IntervalSeconds int    `json:"interval_seconds"`
+	TimeoutSeconds  int    `json:"timeout_seconds"`
+	MaxRetries      int    `json:"max_retries"`
+	CheckURL        string `json:"check_url"`
+}
+
+// LocalForwardConfig is an extra local listener whose connections go through the tunnel to one target (like "ssh -L").
+type LocalForwardConfig struct {
+	Listen  string `json:"listen"`  // local "host:port", e.g. "127.0.0.1:5432"; host defaults to 127.0.0.1
+	Target  string `json:"target"`  // "host:port" as seen from the server, e.g. "db.internal:5432"
+	Network string `json:"network"` // "tcp" (default), "udp" or "tcp,udp"
+}
+
+// ReverseForwardConfig serves a reverse tunnel of the server: connections to its listener are
+// forwarded to Target from this client.
+type ReverseForwardConfig struct {
+	Name   string `json:"name"`   // reverse[].name on the server
+	Target string `json:"target"` // host:port reachable from the client, e.g. "127.0.0.1:22"
 }
 
 // ClientConfig is the root client configuration loaded from abdal-gost-proxy-client.json.
 type ClientConfig struct {
-	LocalPort           int                `json:"local_port"`
-	ServerAddr          string             `json:"server_addr"`
-	ServerPort          int                `json:"server_port"`
-	UUID                string             `json:"uuid"`
-	RealityPublicKey    string             `json:"reality_public_key"`
-	ShortID             string             `json:"short_id"`
-	SNI                 string             `json:"sni"`
-	Fingerprint        string             `json:"fingerprint"`
-	Transport           string             `json:"transport"`
-	ServiceName         string             `json:"service_name"`
-	HealthCheck         HealthCheckConfig  `json:"health_check"`
+	LocalPort           int                    `json:"local_port"`
+	ServerAddr          string                 `json:"server_addr"`
+	ServerPort          int                    `json:"server_port"`
+	ServerPortRange     string                 `json:"server_port_range"` // e.g. "20000-20100": random port per (re)connect
+	UUID                string                 `json:"uuid"`
+	RealityPublicKey    string                 `json:"reality_public_key"`
+	ShortID             string                 `json:"short_id"`
+	SNI                 string                 `json:"sni"`
+	Fingerprint         string                 `json:"fingerprint"`
+	Transport           string                 `json:"transport"`
+	ServiceName         string                 `json:"service_name"`
+	HealthCheck         HealthCheckConfig      `json:"health_check"`
+	DrainTimeoutSeconds int                    `json:"drain_timeout_seconds"` // on shutdown, let open connections finish (0 = close immediately)
+	Metrics             MetricsConfig          `json:"metrics"`
+	Log                 LogConfig              `json:"log"`
+	Forwards            []LocalForwardConfig   `json:"forwards"` // local listeners forwarded to fixed targets, besides SOCKS5
+	Reverse             []ReverseForwardConfig `json:"reverse"`  // reverse tunnels of the server served by this client
 }
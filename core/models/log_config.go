@@ -0,0 +1,31 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : log_config.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 18:04:52
+ * Description : Logging configuration shared by server and client (level, format, file rotation, Xray logs).
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package models
+
+// LogConfig controls application and Xray logging.
+type LogConfig struct {
+	Level             string `json:"level"`               // "debug", "info" (default), "warn" or "error"
+	Format            string `json:"format"`              // "text" (default) or "json"
+	File              string `json:"file"`                // also write to this file; empty = console only
+	MaxSizeMB         int    `json:"max_size_mb"`         // rotate the file when it grows past this size (default 100)
+	RotateHours       int    `json:"rotate_hours"`        // also rotate every N hours (0 = size only)
+	MaxBackups        int    `json:"max_backups"`         // rotated files to keep (0 = keep all)
+	MaxAgeDays        int    `json:"max_age_days"`        // delete rotated files older than this (0 = never)
+	XrayLevel         string `json:"xray_level"`          // Xray error log: "debug", "info", "warning" (default), "error" or "none"
+	DisableXrayAccess bool   `json:"disable_xray_access"` // drop Xray's per-request access lines
+}
@@ -0,0 +1,31 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : metrics_config.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 16:55:30
+ * Description : Metrics options shared by server and client config.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package models
+
+// MetricsConfig enables the Prometheus /metrics endpoint and/or pushing metrics over UDP.
+type MetricsConfig struct {
+	Listen string            `json:"listen"` // loopback "host:port", e.g. "127.0.0.1:9100"; empty = disabled
+	Push   MetricsPushConfig `json:"push"`
+}
+
+// MetricsPushConfig pushes metrics to a StatsD or InfluxDB (line protocol over UDP) collector.
+type MetricsPushConfig struct {
+	Address         string `json:"address"`          // UDP "host:port"; empty = disabled
+	Format          string `json:"format"`           // "statsd" (default) or "influx"
+	IntervalSeconds int    `json:"interval_seconds"` // default 10
+}
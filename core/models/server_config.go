@@ -19,9 +19,31 @@ package models
 
 // ServerUser represents a VLESS client (user) on the server.
 type ServerUser struct {
-	ID    string `json:"id"`
-	Email string `json:"email"`
-	Flow  string `json:"flow"`
+	ID           string        `json:"id"`
+	Email        string        `json:"email"`
+	Flow         string        `json:"flow"`
+	MaxIPs       int           `json:"max_ips,omitempty"`       // distinct source IPs allowed at the same time (0 = ip_limit.default_max_ips)
+	UploadMbps   float64       `json:"upload_mbps,omitempty"`   // shared by all connections of the user (0 = bandwidth.default_upload_mbps)
+	DownloadMbps float64       `json:"download_mbps,omitempty"` // shared by all connections of the user (0 = bandwidth.default_download_mbps)
+	Schedule     *UserSchedule `json:"schedule,omitempty"`      // nil = always allowed
+	QuotaGB      float64       `json:"quota_gb,omitempty"`      // traffic quota (up + down) in GB of 10^9 bytes; 0 = unlimited
+	ExpiresAt    string        `json:"expires_at,omitempty"`    // RFC 3339 time or "YYYY-MM-DD" (valid through that day); empty = never
+	Notes        string        `json:"notes,omitempty"`
+	Group        string        `json:"group,omitempty"`    // user group, routed by egress.groups
+	Outbound     string        `json:"outbound,omitempty"` // egress outbound tag; overrides the group and egress.default_outbound
+}
+
+// UserSchedule restricts a user to time windows; outside them the user's connections are refused and closed.
+type UserSchedule struct {
+	Timezone string           `json:"timezone"` // IANA name, e.g. "Europe/Berlin"; empty = server local time
+	Windows  []ScheduleWindow `json:"windows"`
+}
+
+// ScheduleWindow is a daily time window on the given days.
+type ScheduleWindow struct {
+	Days  []string `json:"days"`  // "mon".."sun", "weekdays", "weekend"; empty = every day
+	Start string   `json:"start"` // "HH:MM"
+	End   string   `json:"end"`   // "HH:MM" ("24:00" = midnight); before start = overnight, ends the next day
 }
 
 // RealitySettings holds XTLS-Reality server configuration.
@@ -42,25 +64,210 @@ type TransportConfig struct {
 
 // FallbackConfig defines fallback destination for unauthenticated traffic (e.g. host:port or port).
 type FallbackConfig struct {
-	Dest       interface{} `json:"dest"`         // string "host:443" or number 80
-	XVer       int         `json:"xver"`
-	DefaultHost string     `json:"default_host"` // when dest is a port number, host to use (optional)
+	Dest        interface{} `json:"dest"` // string "host:443" or number 80
+	XVer        int         `json:"xver"`
+	DefaultHost string      `json:"default_host"` // when dest is a port number, host to use (optional)
+}
+
+// FallbackEntry is one Xray-style fallback rule for TCP-based transports, matched by SNI name, ALPN and HTTP path.
+type FallbackEntry struct {
+	Name string      `json:"name"` // SNI to match (empty = any)
+	Alpn string      `json:"alpn"` // "h2", "http/1.1" or empty (any)
+	Path string      `json:"path"` // HTTP/1.1 path starting with "/" (empty = any)
+	Dest interface{} `json:"dest"` // port number, "host:port", or unix socket path ("/run/web.sock" or "@name")
+	XVer int         `json:"xver"` // PROXY protocol version sent to dest (0, 1 or 2)
+}
+
+// UnixSocketConfig sets permissions and owner when a listen address is a unix socket path.
+type UnixSocketConfig struct {
+	Mode  string `json:"mode"`  // octal permissions, e.g. "0660"
+	Owner string `json:"owner"` // "user", "user:group" or "uid:gid"
+}
+
+// DecoyConfig configures the built-in decoy website that receives fallback traffic.
+type DecoyConfig struct {
+	Enabled      bool             `json:"enabled"`
+	Listen       string           `json:"listen"`        // loopback "host:port" or unix socket path (default 127.0.0.1:18080)
+	RootDir      string           `json:"root_dir"`      // static site directory; empty = built-in template
+	SiteName     string           `json:"site_name"`     // title used by the built-in template
+	ServerHeader string           `json:"server_header"` // "Server" response header (default nginx)
+	UnixSocket   UnixSocketConfig `json:"unix_socket"`
+}
+
+// ProxyProtocolConfig enables PROXY protocol v1/v2 on the VLESS inbound (e.g. behind HAProxy or a cloud TCP load balancer).
+type ProxyProtocolConfig struct {
+	Enabled        bool     `json:"enabled"`
+	TrustedSources []string `json:"trusted_sources"` // CIDRs or IPs allowed to send PROXY headers; empty = require header from every peer
+}
+
+// InboundConfig declares an additional VLESS inbound; nil/empty fields inherit from the root ServerConfig.
+type InboundConfig struct {
+	Tag             string               `json:"tag"`
+	ListenAddress   string               `json:"listen_address"`
+	ListenPort      int                  `json:"listen_port"`
+	PortRange       string               `json:"port_range"`  // e.g. "20000-20100": listen on every port in range (port hopping)
+	UserEmails      []string             `json:"user_emails"` // subset of root users by email; empty = all users
+	Transport       *TransportConfig     `json:"transport"`
+	RealitySettings *RealitySettings     `json:"reality_settings"`
+	Fallbacks       []FallbackEntry      `json:"fallbacks"`
+	ProxyProtocol   *ProxyProtocolConfig `json:"proxy_protocol"`
+	UnixSocket      *UnixSocketConfig    `json:"unix_socket"`
+}
+
+// ReloadConfig controls hot reload of the server config file (SIGHUP always triggers a reload).
+type ReloadConfig struct {
+	WatchFile            bool `json:"watch_file"`             // also reload when the file changes on disk
+	WatchIntervalSeconds int  `json:"watch_interval_seconds"` // poll interval for watch_file (default 5)
+}
+
+// DrainConfig controls graceful shutdown: stop accepting, let open connections finish, then force-close.
+type DrainConfig struct {
+	TimeoutSeconds int `json:"timeout_seconds"` // 0 = close immediately (no drain)
+}
+
+// UpgradeConfig controls zero-downtime binary upgrades (listening sockets are handed to the new process).
+type UpgradeConfig struct {
+	Enabled             bool   `json:"enabled"`               // front every inbound with a handover-capable listener
+	PIDFile             string `json:"pid_file"`              // default abdal-gost-proxy-server.pid (used by the upgrade command)
+	DrainTimeoutSeconds int    `json:"drain_timeout_seconds"` // how long the old process lets connections finish (default 30)
+}
+
+// AccessLogConfig records one line per tunneled connection; the privacy modes limit what is kept.
+type AccessLogConfig struct {
+	Enabled     bool   `json:"enabled"`
+	File        string `json:"file"`        // own rotating file (rotation from log.*); empty = application log
+	SourceIP    string `json:"source_ip"`   // "full" (default), "truncated" (/24, /48), "hashed" or "none"
+	Destination string `json:"destination"` // "full" (default, host:port), "domain" (host name only, no IPs/ports) or "none"
+	HashSalt    string `json:"hash_salt"`   // salt for hashed source IPs; empty = random per process
+}
+
+// ControlConfig enables the local control interface used by the connections and kick commands.
+type ControlConfig struct {
+	Listen     string           `json:"listen"`      // loopback "host:port" or unix socket path; empty = disabled
+	UnixSocket UnixSocketConfig `json:"unix_socket"` // mode defaults to 0600
+	Token      string           `json:"token"`       // required with a TCP listen; clients send "Authorization: Bearer <token>"
+}
+
+// IPLimitConfig limits how many distinct source IPs a user may connect from at the same time.
+type IPLimitConfig struct {
+	DefaultMaxIPs int    `json:"default_max_ips"` // for users without max_ips (0 = unlimited)
+	Policy        string `json:"policy"`          // "reject_newest" (default) or "kick_oldest"
+}
+
+// BandwidthConfig sets the rate limits of users without their own upload_mbps / download_mbps.
+type BandwidthConfig struct {
+	DefaultUploadMbps   float64 `json:"default_upload_mbps"`   // 0 = unlimited
+	DefaultDownloadMbps float64 `json:"default_download_mbps"` // 0 = unlimited
+}
+
+// WebhookConfig is an endpoint that receives server events as signed JSON POST requests.
+type WebhookConfig struct {
+	URL            string   `json:"url"`
+	Secret         string   `json:"secret"`          // HMAC-SHA256 key for X-Abdal-Signature; empty = unsigned
+	Events         []string `json:"events"`          // event types to send; empty = all
+	TimeoutSeconds int      `json:"timeout_seconds"` // per attempt (default 10)
+	MaxRetries     *int     `json:"max_retries"`     // retries with exponential backoff (omitted or negative = 5, 0 = none)
+}
+
+// UserStoreConfig enables the on-disk user database; its users are served in addition to users[].
+type UserStoreConfig struct {
+	Path                string `json:"path"`                  // database file, e.g. "users.db"; empty = disabled
+	SaveIntervalSeconds int    `json:"save_interval_seconds"` // how often traffic counters are written (default 60)
+}
+
+// OutboundConfig is a named egress that users can be routed to (see ServerUser.Outbound and EgressConfig).
+type OutboundConfig struct {
+	Tag         string        `json:"tag"`
+	Type        string        `json:"type"`         // "direct" (default), "socks", "http", "abdal" or "block"
+	SendThrough string        `json:"send_through"` // local source IP of outgoing connections (direct, socks, http, abdal)
+	Address     string        `json:"address"`      // upstream "host:port" (socks, http)
+	Username    string        `json:"username"`     // upstream credentials (optional)
+	Password    string        `json:"password"`
+	Server      *ClientConfig `json:"server,omitempty"` // next-hop Abdal server (abdal): the connection fields of a client config
+	Via         string        `json:"via"`              // reach the upstream through another outbound (multi-hop chains)
+}
+
+// EgressConfig maps user groups and unrouted users to outbounds.
+type EgressConfig struct {
+	DefaultOutbound string            `json:"default_outbound"` // outbound of users without one (default "direct")
+	Groups          map[string]string `json:"groups"`           // group name -> outbound tag
+}
+
+// PolicyConfig restricts where users may connect; refused attempts are counted per user.
+type PolicyConfig struct {
+	AllowPorts     []string `json:"allow_ports"`     // destination ports or ranges ("443", "8000-8999"); empty = all
+	DenyPorts      []string `json:"deny_ports"`      // refused even when allowed, e.g. ["25", "465", "587"]
+	BlockProtocols []string `json:"block_protocols"` // sniffed protocols: "bittorrent", "http", "tls", "quic"
+}
+
+// DNSConfig is the resolver the server uses for destinations instead of the OS resolver.
+type DNSConfig struct {
+	Servers       []DNSServerConfig `json:"servers"`
+	Hosts         map[string]string `json:"hosts"`          // static entries: domain -> IP (or another domain)
+	QueryStrategy string            `json:"query_strategy"` // "ipv4_only", "ipv6_only", "prefer_ipv4", "prefer_ipv6" or "" (both)
+	DisableCache  bool              `json:"disable_cache"`
+}
+
+// DNSServerConfig is one upstream resolver.
+type DNSServerConfig struct {
+	Address      string   `json:"address"`       // "1.1.1.1", "udp://1.1.1.1:53", "tcp://1.1.1.1", "tls://dns.google", "https://dns.google/dns-query", "quic://dns.adguard.com" or "localhost"
+	Domains      []string `json:"domains"`       // ask this server first for these domains ("example.com", "full:", "keyword:", "regexp:")
+	SkipFallback bool     `json:"skip_fallback"` // do not ask this server for other domains
+}
+
+// ReverseTunnelConfig exposes a port on the server that forwards, through the client serving the
+// tunnel, to a host:port reachable from that client (like "ssh -R").
+type ReverseTunnelConfig struct {
+	Name   string `json:"name"`   // shared with the client's reverse[].name
+	Listen string `json:"listen"` // public (or loopback) listener, e.g. "0.0.0.0:8080" or "127.0.0.1:2222"
+	User   string `json:"user"`   // email of the user whose client may serve the tunnel
+}
+
+// AuthBackendConfig pulls authorized users from an HTTP endpoint; they are served in addition to users[].
+type AuthBackendConfig struct {
+	URL             string `json:"url"`              // GET returns a JSON array of users[] entries; empty = disabled
+	Token           string `json:"token"`            // sent as "Authorization: Bearer <token>"; optional
+	IntervalSeconds int    `json:"interval_seconds"` // how often the list is pulled (default 60)
+	TimeoutSeconds  int    `json:"timeout_seconds"`  // per request (default 10)
+	CachePath       string `json:"cache_path"`       // last good list, used while the backend is unreachable (default "auth_backend_cache.json")
 }
 
 // GostConfig holds Gost-specific options for chaining and limits.
 type GostConfig struct {
-	EnableChaining  bool `json:"enable_chaining"`
-	MaxConnections  int  `json:"max_connections"`
+	EnableChaining bool `json:"enable_chaining"`
+	MaxConnections int  `json:"max_connections"`
 }
 
 // ServerConfig is the root server configuration loaded from abdal-gost-proxy-server.json.
 type ServerConfig struct {
-	ListenAddress   string           `json:"listen_address"`
-	ListenPort      int              `json:"listen_port"`
-	Protocol        string           `json:"protocol"`
-	Users           []ServerUser     `json:"users"`
-	RealitySettings RealitySettings  `json:"reality_settings"`
-	Transport       TransportConfig  `json:"transport"`
-	Fallback        FallbackConfig   `json:"fallback"`
-	GostConfig      GostConfig       `json:"gost_config"`
+	ListenAddress   string                `json:"listen_address"` // IP, or unix socket path ("/run/abdal.sock" or "@name")
+	ListenPort      int                   `json:"listen_port"`
+	UnixSocket      UnixSocketConfig      `json:"unix_socket"`
+	Protocol        string                `json:"protocol"`
+	Users           []ServerUser          `json:"users"`
+	RealitySettings RealitySettings       `json:"reality_settings"`
+	Transport       TransportConfig       `json:"transport"`
+	Fallback        FallbackConfig        `json:"fallback"`
+	Fallbacks       []FallbackEntry       `json:"fallbacks"`
+	Decoy           DecoyConfig           `json:"decoy"`
+	ProxyProtocol   ProxyProtocolConfig   `json:"proxy_protocol"`
+	Inbounds        []InboundConfig       `json:"inbounds"`
+	Reload          ReloadConfig          `json:"reload"`
+	Upgrade         UpgradeConfig         `json:"upgrade"`
+	Drain           DrainConfig           `json:"drain"`
+	Metrics         MetricsConfig         `json:"metrics"`
+	Log             LogConfig             `json:"log"`
+	AccessLog       AccessLogConfig       `json:"access_log"`
+	Control         ControlConfig         `json:"control"`
+	IPLimit         IPLimitConfig         `json:"ip_limit"`
+	Bandwidth       BandwidthConfig       `json:"bandwidth"`
+	Webhooks        []WebhookConfig       `json:"webhooks"`
+	UserStore       UserStoreConfig       `json:"user_store"`
+	AuthBackend     AuthBackendConfig     `json:"auth_backend"`
+	Outbounds       []OutboundConfig      `json:"outbounds"`
+	Egress          EgressConfig          `json:"egress"`
+	Policy          PolicyConfig          `json:"policy"`
+	DNS             DNSConfig             `json:"dns"`
+	Reverse         []ReverseTunnelConfig `json:"reverse"`
+	GostConfig      GostConfig            `json:"gost_config"`
 }
@@ -0,0 +1,74 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : drain.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 15:02:31
+ * Description : Connection drain with deadline, force-close count and progress reporting.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package netutil
+
+import (
+	"sync"
+	"time"
+)
+
+// DrainStatus is a snapshot of a running or finished drain.
+type DrainStatus struct {
+	Draining    bool      `json:"draining"`
+	Active      int       `json:"active"`       // connections still open
+	Deadline    time.Time `json:"deadline"`     // when the remaining connections are force-closed
+	ForceClosed int       `json:"force_closed"` // set once the drain has finished
+}
+
+// Drainer waits for connections to finish and keeps the progress readable from other goroutines.
+type Drainer struct {
+	status DrainStatus
+	mu     sync.Mutex
+}
+
+// Run polls active until it reaches zero or timeout passes, then calls forceClose for the rest.
+// report (optional) receives the status about once per second. Returns the number of force-closed connections.
+func (d *Drainer) Run(timeout time.Duration, active func() int, forceClose func() int, report func(DrainStatus)) int {
+	deadline := time.Now().Add(timeout)
+	d.set(DrainStatus{Draining: true, Active: active(), Deadline: deadline})
+	lastReport := time.Time{}
+	for {
+		st := d.Status()
+		if st.Active == 0 || !time.Now().Before(deadline) {
+			break
+		}
+		if report != nil && time.Since(lastReport) >= time.Second {
+			report(st)
+			lastReport = time.Now()
+		}
+		time.Sleep(250 * time.Millisecond)
+		st.Active = active()
+		d.set(st)
+	}
+	forced := forceClose()
+	d.set(DrainStatus{Deadline: deadline, ForceClosed: forced})
+	return forced
+}
+
+// Status returns the current drain progress.
+func (d *Drainer) Status() DrainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+func (d *Drainer) set(st DrainStatus) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = st
+}
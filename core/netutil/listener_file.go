@@ -0,0 +1,42 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : listener_file.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 14:05:12
+ * Description : Duplicates listening sockets as files so they can be inherited by another process.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// ListenerFile returns a duplicate of the listener's socket (the listener keeps working).
+func ListenerFile(ln net.Listener) (*os.File, error) {
+	switch l := ln.(type) {
+	case *net.TCPListener:
+		return l.File()
+	case *net.UnixListener:
+		return l.File()
+	}
+	return nil, fmt.Errorf("listener %T cannot be handed over", ln)
+}
+
+// KeepUnixSocket stops Close from removing the socket file (another process now serves it).
+func KeepUnixSocket(ln net.Listener) {
+	if l, ok := ln.(*net.UnixListener); ok {
+		l.SetUnlinkOnClose(false)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : port_range.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 11:40:27
+ * Description : Port range parsing and random port selection for port hopping.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package netutil
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// ParsePortRange parses "from-to" (or a single port) into inclusive bounds.
+func ParsePortRange(s string) (from, to int, err error) {
+	s = strings.TrimSpace(s)
+	lo, hi, found := strings.Cut(s, "-")
+	from, err = strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil {
+		return 0, 0, fmt.Errorf("port range %q: invalid start", s)
+	}
+	to = from
+	if found {
+		to, err = strconv.Atoi(strings.TrimSpace(hi))
+		if err != nil {
+			return 0, 0, fmt.Errorf("port range %q: invalid end", s)
+		}
+	}
+	if from < 1 || to > 65535 || from > to {
+		return 0, 0, fmt.Errorf("port range %q: must be within 1-65535 and start <= end", s)
+	}
+	return from, to, nil
+}
+
+// RandomPort returns a random port from the range "from-to".
+func RandomPort(s string) (int, error) {
+	from, to, err := ParsePortRange(s)
+	if err != nil {
+		return 0, err
+	}
+	return from + rand.Intn(to-from+1), nil
+}
@@ -0,0 +1,153 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : unix_socket.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 12:20:51
+ * Description : Unix domain socket helpers: listen with permissions and owner, Xray listen suffix.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// IsAbstractSocket reports whether path names a Linux abstract socket ("@name"), which has no file to chmod/chown.
+func IsAbstractSocket(path string) bool {
+	return strings.HasPrefix(path, "@")
+}
+
+// ParseSocketMode parses an octal permission string such as "0660"; empty means no change.
+func ParseSocketMode(mode string) (os.FileMode, bool, error) {
+	if mode == "" {
+		return 0, false, nil
+	}
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > 0777 {
+		return 0, false, fmt.Errorf("unix socket mode %q: must be octal like 0660", mode)
+	}
+	return os.FileMode(perm), true, nil
+}
+
+// XrayListenAddr returns the Xray listen value for a socket path, with the ",mode" suffix Xray uses for permissions.
+func XrayListenAddr(path, mode string) (string, error) {
+	if _, ok, err := ParseSocketMode(mode); err != nil || !ok || IsAbstractSocket(path) {
+		return path, err
+	}
+	return path + "," + mode, nil
+}
+
+// ListenUnix listens on a unix socket path (removing a stale file) and applies mode and owner.
+func ListenUnix(path, mode, owner string) (net.Listener, error) {
+	perm, hasMode, err := ParseSocketMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	if !IsAbstractSocket(path) {
+		_ = os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := applySocketPerms(path, perm, hasMode, owner); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// SetSocketPerms applies mode and owner to an existing socket file (e.g. one taken over from a previous listener).
+func SetSocketPerms(path, mode, owner string) error {
+	perm, hasMode, err := ParseSocketMode(mode)
+	if err != nil {
+		return err
+	}
+	return applySocketPerms(path, perm, hasMode, owner)
+}
+
+func applySocketPerms(path string, perm os.FileMode, hasMode bool, owner string) error {
+	if IsAbstractSocket(path) {
+		return nil
+	}
+	if hasMode {
+		if err := os.Chmod(path, perm); err != nil {
+			return err
+		}
+	}
+	return ChownSocket(path, owner)
+}
+
+// ChownSocket changes the owner of a socket file; owner is "user", "user:group" or "uid:gid" (empty = no change).
+func ChownSocket(path, owner string) error {
+	if owner == "" || IsAbstractSocket(path) {
+		return nil
+	}
+	userPart, groupPart, _ := strings.Cut(owner, ":")
+	uid, gid := -1, -1
+	if userPart != "" {
+		id, err := lookupID(userPart, true)
+		if err != nil {
+			return err
+		}
+		uid = id
+		if groupPart == "" {
+			if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+				if g, err := strconv.Atoi(u.Gid); err == nil {
+					gid = g
+				}
+			}
+		}
+	}
+	if groupPart != "" {
+		id, err := lookupID(groupPart, false)
+		if err != nil {
+			return err
+		}
+		gid = id
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("chown %s to %s: %v", path, owner, err)
+	}
+	return nil
+}
+
+// lookupID resolves a numeric id or a user/group name.
+func lookupID(name string, isUser bool) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	var idStr string
+	if isUser {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return 0, err
+		}
+		idStr = u.Uid
+	} else {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return 0, err
+		}
+		idStr = g.Gid
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, fmt.Errorf("%s: non-numeric id %q", name, idStr)
+	}
+	return id, nil
+}
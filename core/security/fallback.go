@@ -6,7 +6,7 @@
  * Author : Ebrahim Shafiei (EbraSha)
  * Email : Prof.Shafiei@Gmail.com
  * Created On : 2026-02-14 22:16:06
- * Description : Fallback destination resolution for unauthenticated traffic (e.g. samsung.com).
+ * Description : Fallback destination resolution for unauthenticated traffic (e.g. samsung.com) and VLESS fallback rules.
  * -------------------------------------------------------------------
  *
  * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
@@ -19,9 +19,22 @@ package security
 
 import (
 	"fmt"
+	"net"
 	"strconv"
+	"strings"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
 )
 
+// FallbackParams holds one validated VLESS fallback rule for building Xray config.
+type FallbackParams struct {
+	Name string
+	Alpn string
+	Path string
+	Dest string
+	XVer int
+}
+
 // ResolveFallbackDest returns a "host:port" string from config dest (string or number).
 func ResolveFallbackDest(dest interface{}, defaultHost string) string {
 	if dest == nil {
@@ -41,3 +54,84 @@ func ResolveFallbackDest(dest interface{}, defaultHost string) string {
 		return fmt.Sprintf("%v", v)
 	}
 }
+
+// IsUnixSocketPath reports whether addr names a unix domain socket ("/path" or abstract "@name").
+func IsUnixSocketPath(addr string) bool {
+	return strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, "@")
+}
+
+// ResolveFallbackTarget returns "host:port" or a unix socket path for a VLESS fallback dest.
+// A bare port number uses defaultHost, or loopback when defaultHost is empty.
+func ResolveFallbackTarget(dest interface{}, defaultHost string) (string, error) {
+	if defaultHost == "" {
+		defaultHost = "127.0.0.1"
+	}
+	var port int
+	switch v := dest.(type) {
+	case nil:
+		return "", fmt.Errorf("fallback dest is empty")
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("fallback dest is empty")
+		}
+		if IsUnixSocketPath(v) {
+			return v, nil
+		}
+		if n, err := strconv.Atoi(v); err == nil {
+			port = n
+			break
+		}
+		if _, _, err := net.SplitHostPort(v); err != nil {
+			return "", fmt.Errorf("fallback dest %q: %v", v, err)
+		}
+		return v, nil
+	case float64:
+		port = int(v)
+	case int:
+		port = v
+	default:
+		return "", fmt.Errorf("fallback dest %v: unsupported type %T", v, v)
+	}
+	if port <= 0 || port > 65535 {
+		return "", fmt.Errorf("fallback dest port %d out of range", port)
+	}
+	return net.JoinHostPort(defaultHost, strconv.Itoa(port)), nil
+}
+
+// FromServerFallbacks validates fallback entries and returns them as Xray fallback rules.
+// The legacy single fallback (when its dest is set) is appended as catch-all unless an entry already matches everything.
+func FromServerFallbacks(entries []models.FallbackEntry, legacy *models.FallbackConfig) ([]FallbackParams, error) {
+	out := make([]FallbackParams, 0, len(entries)+1)
+	seen := make(map[string]bool, len(entries))
+	for i, e := range entries {
+		dest, err := ResolveFallbackTarget(e.Dest, "")
+		if err != nil {
+			return nil, fmt.Errorf("fallbacks[%d]: %v", i, err)
+		}
+		switch e.Alpn {
+		case "", "h2", "http/1.1":
+		default:
+			return nil, fmt.Errorf("fallbacks[%d]: alpn must be empty, \"h2\" or \"http/1.1\"", i)
+		}
+		if e.Path != "" && !strings.HasPrefix(e.Path, "/") {
+			return nil, fmt.Errorf("fallbacks[%d]: path must be empty or start with \"/\"", i)
+		}
+		if e.XVer < 0 || e.XVer > 2 {
+			return nil, fmt.Errorf("fallbacks[%d]: xver must be 0, 1 or 2", i)
+		}
+		key := e.Name + "\x00" + e.Alpn + "\x00" + e.Path
+		if seen[key] {
+			return nil, fmt.Errorf("fallbacks[%d]: duplicate name/alpn/path match", i)
+		}
+		seen[key] = true
+		out = append(out, FallbackParams{Name: e.Name, Alpn: e.Alpn, Path: e.Path, Dest: dest, XVer: e.XVer})
+	}
+	if legacy != nil && legacy.Dest != nil && legacy.Dest != "" && !seen["\x00\x00"] {
+		dest, err := ResolveFallbackTarget(legacy.Dest, legacy.DefaultHost)
+		if err != nil {
+			return nil, fmt.Errorf("fallback: %v", err)
+		}
+		out = append(out, FallbackParams{Dest: dest, XVer: legacy.XVer})
+	}
+	return out, nil
+}
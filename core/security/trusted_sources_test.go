@@ -0,0 +1,76 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : trusted_sources_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 06:02:44
+ * Description : Tests for parsing PROXY protocol trusted sources and matching peers against them.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package security
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseTrustedSources(t *testing.T) {
+	nets, err := ParseTrustedSources([]string{"10.0.0.0/8", " 192.0.2.7 ", "2001:db8::/32", "2001:db8:ffff::1", "::ffff:198.51.100.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.7/32", "2001:db8::/32", "2001:db8:ffff::1/128", "198.51.100.9/32"}
+	if len(nets) != len(want) {
+		t.Fatalf("got %v, want %v", nets, want)
+	}
+	for i, n := range nets {
+		if n.String() != want[i] {
+			t.Errorf("[%d] = %s, want %s", i, n, want[i])
+		}
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "192.0.2", "example.com", "", "10.0.0.0/"} {
+		if _, err := ParseTrustedSources([]string{"10.0.0.0/8", bad}); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if nets, err := ParseTrustedSources(nil); err != nil || len(nets) != 0 {
+		t.Errorf("empty list: %v, %v", nets, err)
+	}
+}
+
+func TestIsTrustedSource(t *testing.T) {
+	nets, err := ParseTrustedSources([]string{"10.0.0.0/8", "192.0.2.7", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr net.Addr
+		want bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 40000}, true},
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:10.1.2.3"), Port: 40000}, true},
+		{&net.TCPAddr{IP: net.ParseIP("11.0.0.1"), Port: 40000}, false},
+		{&net.TCPAddr{IP: net.ParseIP("192.0.2.7"), Port: 1}, true},
+		{&net.TCPAddr{IP: net.ParseIP("192.0.2.8"), Port: 1}, false},
+		{&net.UDPAddr{IP: net.ParseIP("2001:db8::53"), Port: 53}, true},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db9::1"), Port: 443}, false},
+		{&net.UnixAddr{Name: "/run/lb.sock", Net: "unix"}, false},
+	}
+	for _, tt := range tests {
+		if got := IsTrustedSource(nets, tt.addr); got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+	if IsTrustedSource(nil, &net.TCPAddr{IP: net.ParseIP("10.1.2.3")}) {
+		t.Error("trusted without any sources")
+	}
+}
@@ -20,24 +20,79 @@ package client
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
 	"sync"
+	"time"
 
-	"github.com/ebrasha/abdal-gost-proxy/core/colors"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/metrics"
 	"github.com/ebrasha/abdal-gost-proxy/core/models"
 )
 
 // Run starts the Abdal Gost Proxy client: Xray SOCKS5 on local_port (from config) -> VLESS+Reality+gRPC; health check and re-dial.
 func Run(ctx context.Context, cfg *models.ClientConfig) error {
-	runner, err := NewXrayClientRunner(cfg)
+	registry := metrics.NewRegistry()
+	registry.RegisterBuildInfo("client")
+	registry.RegisterRuntime()
+	traffic := registry.NewTrafficCollector("abdal_client", false)
+	runner, err := NewXrayClientRunner(cfg, traffic)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = runner.Close() }()
+	registry.Register(metrics.CollectorFunc(func() []metrics.Family {
+		return []metrics.Family{{
+			Name: "abdal_client_restarts_total", Help: "Tunnel restarts (re-dials) after failed health checks.", Type: "counter",
+			Samples: []metrics.Sample{{Name: "abdal_client_restarts_total", Value: float64(runner.Restarts())}},
+		}}
+	}))
+	if cfg.Metrics.Listen != "" {
+		if err := metrics.CheckListen(cfg.Metrics.Listen); err != nil {
+			return err
+		}
+		ln, err := net.Listen("tcp", cfg.Metrics.Listen)
+		if err != nil {
+			return fmt.Errorf("metrics: %v", err)
+		}
+		srv := metrics.NewServer(ln, registry)
+		defer func() { _ = srv.Close() }()
+		logger.Infof("metrics on http://%s/metrics", cfg.Metrics.Listen)
+	}
+	if push := cfg.Metrics.Push; push.Address != "" {
+		pusher, err := metrics.NewPusher(registry, metrics.PushOptions{
+			Address:  push.Address,
+			Format:   push.Format,
+			Interval: time.Duration(push.IntervalSeconds) * time.Second,
+			Prefix:   "abdal_client_",
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = pusher.Close() }()
+		logger.Infof("pushing metrics to udp://%s", push.Address)
+	}
 
 	var wg sync.WaitGroup
-	fmt.Print(colors.Cyan(fmt.Sprintf("[Abdal Gost Proxy client] SOCKS5 on 127.0.0.1:%d -> %s:%d (VLESS+Reality+gRPC)\n", cfg.LocalPort, cfg.ServerAddr, cfg.ServerPort)))
+	serverPort := strconv.Itoa(cfg.ServerPort)
+	if cfg.ServerPortRange != "" {
+		serverPort = cfg.ServerPortRange
+	}
+	logger.Infof("SOCKS5 on 127.0.0.1:%d -> %s:%s (VLESS+Reality+gRPC)", cfg.LocalPort, cfg.ServerAddr, serverPort)
+	for _, f := range cfg.Forwards {
+		network := f.Network
+		if network == "" {
+			network = "tcp"
+		}
+		logger.Infof("forwarding %s -> %s (%s)", f.Listen, f.Target, network)
+	}
+	for _, f := range cfg.Reverse {
+		logger.Infof("reverse tunnel %s -> %s", f.Name, f.Target)
+	}
 
 	health := NewHealthChecker(cfg, runner)
+	health.results = registry.NewCounterVec("abdal_client_health_checks_total", "Health checks by result.", "result")
+	health.latency = registry.NewHistogramVec("abdal_client_health_check_latency_seconds", "Latency of successful health checks.", metrics.DefaultLatencyBuckets)
 	if cfg.HealthCheck.Enabled {
 		wg.Add(1)
 		go func() {
@@ -0,0 +1,97 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : forward.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 03:12:36
+ * Description : Local port forwarding: extra client listeners that send TCP/UDP to fixed targets through the tunnel.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// clientDokodemo is the settings of a forward listener.
+type clientDokodemo struct {
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	Network string `json:"network"`
+}
+
+// forwardTag returns the Xray tag of cfg.Forwards[i].
+func forwardTag(i int) string {
+	return "forward-" + strconv.Itoa(i+1)
+}
+
+// localInboundTags returns the tags of every local listener (SOCKS5 and forwards).
+func localInboundTags(cfg *models.ClientConfig) []string {
+	tags := []string{localInboundTag}
+	for i := range cfg.Forwards {
+		tags = append(tags, forwardTag(i))
+	}
+	return tags
+}
+
+// splitHostPort splits "host:port" and checks the port.
+func splitHostPort(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("port %q: must be 1-65535", portStr)
+	}
+	return host, port, nil
+}
+
+// buildForwards returns one dokodemo-door inbound per forward; the default outbound sends them through the tunnel.
+func buildForwards(cfg *models.ClientConfig) ([]clientInbound, error) {
+	inbounds := make([]clientInbound, 0, len(cfg.Forwards))
+	for i, f := range cfg.Forwards {
+		host, port, err := splitHostPort(f.Listen)
+		if err != nil {
+			return nil, fmt.Errorf("forwards[%d]: listen %q: %v", i, f.Listen, err)
+		}
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		targetHost, targetPort, err := splitHostPort(f.Target)
+		if err == nil && targetHost == "" {
+			err = fmt.Errorf("host is required")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("forwards[%d]: target %q: %v", i, f.Target, err)
+		}
+		network := f.Network
+		switch network {
+		case "":
+			network = "tcp"
+		case "tcp", "udp", "tcp,udp":
+		default:
+			return nil, fmt.Errorf("forwards[%d]: network %q: must be tcp, udp or tcp,udp", i, f.Network)
+		}
+		inbounds = append(inbounds, clientInbound{
+			Listen:   host,
+			Port:     port,
+			Protocol: "dokodemo-door",
+			Tag:      forwardTag(i),
+			Settings: &clientDokodemo{Address: targetHost, Port: targetPort, Network: network},
+		})
+	}
+	return inbounds, nil
+}
@@ -19,20 +19,22 @@ package client
 
 import (
 	"context"
-	"fmt"
 	"net"
 	"net/url"
 	"strconv"
 	"time"
 
-	"github.com/ebrasha/abdal-gost-proxy/core/colors"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/metrics"
 	"github.com/ebrasha/abdal-gost-proxy/core/models"
 )
 
 // HealthChecker runs periodic checks and triggers re-dial on failure.
 type HealthChecker struct {
-	cfg    *models.ClientConfig
-	runner *XrayClientRunner
+	cfg     *models.ClientConfig
+	runner  *XrayClientRunner
+	results *metrics.CounterVec   // optional: checks by result
+	latency *metrics.HistogramVec // optional: successful check latency in seconds
 }
 
 // NewHealthChecker creates a health checker (does not start).
@@ -71,17 +73,19 @@ func (h *HealthChecker) Run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			started := time.Now()
 			ok := h.checkOnce(ctx, timeout, checkURL)
+			h.record(ok, time.Since(started))
 			if ok {
 				failCount = 0
 				continue
 			}
 			failCount++
-			fmt.Print(colors.Yellow(fmt.Sprintf("[Abdal Gost Proxy client] health check failed (%d/%d)\n", failCount, maxRetries)))
+			logger.Warnf("health check failed (%d/%d)", failCount, maxRetries)
 			if failCount >= maxRetries {
-				fmt.Print(colors.Magenta("[Abdal Gost Proxy client] triggering re-dial (restart tunnel)\n"))
+				logger.Warnf("triggering re-dial (restart tunnel)")
 				if err := h.runner.Restart(); err != nil {
-					fmt.Print(colors.Red(fmt.Sprintf("[Abdal Gost Proxy client] re-dial error: %v\n", err)))
+					logger.Errorf("re-dial error: %v", err)
 				} else {
 					failCount = 0
 				}
@@ -90,6 +94,19 @@ func (h *HealthChecker) Run(ctx context.Context) {
 	}
 }
 
+// record exports one check result when metrics are enabled.
+func (h *HealthChecker) record(ok bool, took time.Duration) {
+	if h.results == nil {
+		return
+	}
+	if !ok {
+		h.results.Inc("fail")
+		return
+	}
+	h.results.Inc("ok")
+	h.latency.Observe(took.Seconds())
+}
+
 // checkOnce performs one health check via local SOCKS5 proxy.
 func (h *HealthChecker) checkOnce(ctx context.Context, timeout time.Duration, checkURL string) bool {
 	u, err := url.Parse(checkURL)
@@ -0,0 +1,117 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : reverse.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 02:47:19
+ * Description : Reverse tunnels served by the client: Xray bridges that forward the server's listeners to local targets.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// reverseOutboundTag is the untracked copy of the proxy outbound that carries the bridge connections,
+// so they neither show up as open connections nor hold up a drain.
+const reverseOutboundTag = "reverse-tunnel"
+
+// reverseDomainSuffix completes the internal domain that pairs a bridge with the server's portal.
+const reverseDomainSuffix = ".reverse.abdal.internal"
+
+var reverseNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type clientReverse struct {
+	Bridges []clientBridge `json:"bridges"`
+}
+
+type clientBridge struct {
+	Tag    string `json:"tag"`
+	Domain string `json:"domain"`
+}
+
+type clientRouting struct {
+	Rules []clientRule `json:"rules"`
+}
+
+type clientRule struct {
+	Type        string   `json:"type"`
+	InboundTag  []string `json:"inboundTag"`
+	Domain      []string `json:"domain,omitempty"`
+	OutboundTag string   `json:"outboundTag"`
+}
+
+// clientForward is a freedom outbound that sends every connection to one target.
+type clientForward struct {
+	Protocol string `json:"protocol"`
+	Tag      string `json:"tag"`
+	Settings struct {
+		Redirect string `json:"redirect"`
+	} `json:"settings"`
+}
+
+// ReverseDomain returns the internal domain of the reverse tunnel name, shared by the client's bridge
+// and the server's portal.
+func ReverseDomain(name string) (string, error) {
+	if !reverseNamePattern.MatchString(name) {
+		return "", fmt.Errorf("name %q: must be lowercase letters, digits and '-'", name)
+	}
+	return name + reverseDomainSuffix, nil
+}
+
+// ReverseTag returns the tag of a reverse tunnel's parts (bridge and target outbound here, listener and portal on the server).
+func ReverseTag(name string) string {
+	return "reverse-" + name
+}
+
+// buildReverse returns the bridges of cfg.Reverse with their outbounds and routing rules (nil when none are configured).
+func buildReverse(cfg *models.ClientConfig, proxy clientOutboundVless) (*clientReverse, []interface{}, []clientRule, error) {
+	if len(cfg.Reverse) == 0 {
+		return nil, nil, nil, nil
+	}
+	proxy.Tag = reverseOutboundTag
+	rev := &clientReverse{}
+	outbounds := []interface{}{proxy}
+	var rules []clientRule
+	seen := make(map[string]bool, len(cfg.Reverse))
+	for i, f := range cfg.Reverse {
+		domain, err := ReverseDomain(f.Name)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("reverse[%d]: %v", i, err)
+		}
+		if seen[f.Name] {
+			return nil, nil, nil, fmt.Errorf("reverse %s: name used twice", f.Name)
+		}
+		seen[f.Name] = true
+		host, portStr, err := net.SplitHostPort(f.Target)
+		port, perr := strconv.Atoi(portStr)
+		if err != nil || host == "" || perr != nil || port < 1 || port > 65535 {
+			return nil, nil, nil, fmt.Errorf("reverse %s: target %q: must be host:port", f.Name, f.Target)
+		}
+		tag := ReverseTag(f.Name)
+		rev.Bridges = append(rev.Bridges, clientBridge{Tag: tag, Domain: domain})
+		fwd := clientForward{Protocol: "freedom", Tag: tag}
+		fwd.Settings.Redirect = f.Target
+		outbounds = append(outbounds, fwd)
+		// The bridge's own connection goes to the server; what the server sends back goes to the target.
+		rules = append(rules,
+			clientRule{Type: "field", InboundTag: []string{tag}, Domain: []string{"full:" + domain}, OutboundTag: reverseOutboundTag},
+			clientRule{Type: "field", InboundTag: []string{tag}, OutboundTag: tag},
+		)
+	}
+	return rev, outbounds, rules, nil
+}
@@ -21,23 +21,28 @@ import (
 	"bytes"
 	"encoding/json"
 
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
 	"github.com/ebrasha/abdal-gost-proxy/core/models"
 )
 
-// clientInbound represents SOCKS5 inbound in Xray client config.
+// localInboundTag is the Xray tag of the local SOCKS5 inbound.
+const localInboundTag = "socks-in"
+
+// clientInbound represents the SOCKS5 inbound (or a forward listener) in Xray client config.
 type clientInbound struct {
-	Listen   string `json:"listen"`
-	Port     int    `json:"port"`
-	Protocol string `json:"protocol"`
-	Tag      string `json:"tag"`
+	Listen   string      `json:"listen"`
+	Port     int         `json:"port"`
+	Protocol string      `json:"protocol"`
+	Tag      string      `json:"tag"`
+	Settings interface{} `json:"settings,omitempty"` // *clientDokodemo for forwards
 }
 
 // clientOutboundVless represents VLESS outbound in Xray client config.
 type clientOutboundVless struct {
-	Protocol string              `json:"protocol"`
-	Tag      string              `json:"tag"`
-	Settings *clientVlessSettings `json:"settings"`
-	StreamSettings *clientStream `json:"streamSettings"`
+	Protocol       string               `json:"protocol"`
+	Tag            string               `json:"tag"`
+	Settings       *clientVlessSettings `json:"settings"`
+	StreamSettings *clientStream        `json:"streamSettings"`
 }
 
 type clientVlessSettings struct {
@@ -45,9 +50,9 @@ type clientVlessSettings struct {
 }
 
 type clientVnext struct {
-	Address string        `json:"address"`
-	Port    int           `json:"port"`
-	Users   []clientUser  `json:"users"`
+	Address string       `json:"address"`
+	Port    int          `json:"port"`
+	Users   []clientUser `json:"users"`
 }
 
 type clientUser struct {
@@ -57,18 +62,18 @@ type clientUser struct {
 }
 
 type clientStream struct {
-	Network   string           `json:"network"`
-	Security  string           `json:"security"`
+	Network         string         `json:"network"`
+	Security        string         `json:"security"`
 	RealitySettings *clientReality `json:"realitySettings,omitempty"`
-	GRPCSettings   *clientGRPC   `json:"grpcSettings,omitempty"`
+	GRPCSettings    *clientGRPC    `json:"grpcSettings,omitempty"`
 }
 
 type clientReality struct {
-	Show          bool   `json:"show"`
-	Fingerprint   string `json:"fingerprint"`
-	ServerName    string `json:"serverName"`
-	PublicKey     string `json:"publicKey"`
-	ShortID       string `json:"shortId"`
+	Show        bool   `json:"show"`
+	Fingerprint string `json:"fingerprint"`
+	ServerName  string `json:"serverName"`
+	PublicKey   string `json:"publicKey"`
+	ShortID     string `json:"shortId"`
 }
 
 type clientGRPC struct {
@@ -76,23 +81,87 @@ type clientGRPC struct {
 }
 
 type clientConfig struct {
-	Log       *xrayLogClient   `json:"log,omitempty"`
-	Inbounds  []clientInbound   `json:"inbounds"`
-	Outbounds []interface{}    `json:"outbounds"`
+	Log       *xrayLogClient  `json:"log,omitempty"`
+	Inbounds  []clientInbound `json:"inbounds"`
+	Outbounds []interface{}   `json:"outbounds"`
+	Routing   *clientRouting  `json:"routing,omitempty"`
+	Reverse   *clientReverse  `json:"reverse,omitempty"`
 }
 
 type xrayLogClient struct {
 	Loglevel string `json:"loglevel"`
+	Access   string `json:"access,omitempty"` // "none" disables the access log
 }
 
 // InternalSocksPort is used only as fallback when localPort from config is missing or <= 0.
 const InternalSocksPort = 10809
 
-// BuildXrayClientJSON produces Xray client config JSON (SOCKS5 inbound on localPort from config, VLESS+Reality+gRPC out).
+// BuildXrayClientJSON produces Xray client config JSON (SOCKS5 inbound on localPort from config plus forward listeners, VLESS+Reality+gRPC out).
 func BuildXrayClientJSON(cfg *models.ClientConfig, localPort int) ([]byte, error) {
 	if localPort <= 0 {
 		localPort = InternalSocksPort
 	}
+	settings, streamSettings := buildProxyOutbound(cfg)
+	proxy := clientOutboundVless{
+		Protocol:       "vless",
+		Tag:            "proxy",
+		Settings:       settings,
+		StreamSettings: streamSettings,
+	}
+
+	logLevel, access := logger.XrayLogConfig(&cfg.Log)
+	xcfg := clientConfig{
+		Log: &xrayLogClient{Loglevel: logLevel, Access: access},
+		Inbounds: []clientInbound{{
+			Listen:   "127.0.0.1",
+			Port:     localPort,
+			Protocol: "socks",
+			Tag:      localInboundTag,
+		}},
+		Outbounds: []interface{}{
+			proxy,
+			struct {
+				Protocol string `json:"protocol"`
+				Tag      string `json:"tag"`
+			}{Protocol: "freedom", Tag: "direct"},
+			struct {
+				Protocol string `json:"protocol"`
+				Tag      string `json:"tag"`
+			}{Protocol: "blackhole", Tag: "block"},
+		},
+	}
+	forwards, err := buildForwards(cfg)
+	if err != nil {
+		return nil, err
+	}
+	xcfg.Inbounds = append(xcfg.Inbounds, forwards...)
+	reverse, outbounds, rules, err := buildReverse(cfg, proxy)
+	if err != nil {
+		return nil, err
+	}
+	if reverse != nil {
+		xcfg.Outbounds = append(xcfg.Outbounds, outbounds...)
+		xcfg.Routing = &clientRouting{Rules: rules}
+		xcfg.Reverse = reverse
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(xcfg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BuildProxyOutbound returns the settings and streamSettings of the VLESS+Reality outbound to the
+// server of cfg, ready to be JSON-encoded into an Xray outbound (used by relay servers for their next hop).
+func BuildProxyOutbound(cfg *models.ClientConfig) (settings, streamSettings interface{}) {
+	return buildProxyOutbound(cfg)
+}
+
+// buildProxyOutbound is BuildProxyOutbound with the concrete types of the client config.
+func buildProxyOutbound(cfg *models.ClientConfig) (*clientVlessSettings, *clientStream) {
 	serviceName := cfg.ServiceName
 	if serviceName == "" {
 		serviceName = "abdal-grpc-stream"
@@ -121,48 +190,16 @@ func BuildXrayClientJSON(cfg *models.ClientConfig, localPort int) ([]byte, error
 	if network == "grpc" {
 		streamSettings.GRPCSettings = &clientGRPC{ServiceName: serviceName}
 	}
-
-	xcfg := clientConfig{
-		Log: &xrayLogClient{Loglevel: "warning"},
-		Inbounds: []clientInbound{{
-			Listen:   "127.0.0.1",
-			Port:     localPort,
-			Protocol: "socks",
-			Tag:      "socks-in",
+	settings := &clientVlessSettings{
+		Vnext: []clientVnext{{
+			Address: cfg.ServerAddr,
+			Port:    cfg.ServerPort,
+			Users: []clientUser{{
+				ID:         cfg.UUID,
+				Encryption: "none",
+				Flow:       "", // empty for gRPC; "xtls-rprx-vision" only for direct TCP+TLS/Reality
+			}},
 		}},
-		Outbounds: []interface{}{
-			clientOutboundVless{
-				Protocol: "vless",
-				Tag:      "proxy",
-				Settings: &clientVlessSettings{
-					Vnext: []clientVnext{{
-						Address: cfg.ServerAddr,
-						Port:    cfg.ServerPort,
-						Users: []clientUser{{
-							ID:         cfg.UUID,
-							Encryption: "none",
-							Flow:       "", // empty for gRPC; "xtls-rprx-vision" only for direct TCP+TLS/Reality
-						}},
-					}},
-				},
-				StreamSettings: streamSettings,
-			},
-			struct {
-				Protocol string `json:"protocol"`
-				Tag      string `json:"tag"`
-			}{Protocol: "freedom", Tag: "direct"},
-			struct {
-				Protocol string `json:"protocol"`
-				Tag      string `json:"tag"`
-			}{Protocol: "blackhole", Tag: "block"},
-		},
 	}
-
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
-	enc.SetEscapeHTML(false)
-	if err := enc.Encode(xcfg); err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+	return settings, streamSettings
 }
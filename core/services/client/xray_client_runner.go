@@ -21,31 +21,54 @@ import (
 	"bytes"
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
 	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/netutil"
 	"github.com/xtls/xray-core/core"
-	_ "github.com/xtls/xray-core/main/distro/all"
+	"github.com/xtls/xray-core/features/inbound"
 	"github.com/xtls/xray-core/infra/conf/serial"
+	_ "github.com/xtls/xray-core/main/distro/all"
 )
 
 // XrayClientRunner runs Xray client and supports restart (re-dial).
 type XrayClientRunner struct {
-	config   *models.ClientConfig
-	instance *core.Instance
-	mu       sync.Mutex
+	config    *models.ClientConfig
+	instance  *core.Instance
+	tracker   *conntrack.Tracker
+	observers []conntrack.Observer
+	restarts  atomic.Int64
+	drainer   netutil.Drainer
+	mu        sync.Mutex
 }
 
 // NewXrayClientRunner creates runner and starts Xray client (SOCKS5 on local_port).
-func NewXrayClientRunner(cfg *models.ClientConfig) (*XrayClientRunner, error) {
-	r := &XrayClientRunner{config: cfg}
+// observers are attached to the connection tracker of every (re)started instance.
+func NewXrayClientRunner(cfg *models.ClientConfig, observers ...conntrack.Observer) (*XrayClientRunner, error) {
+	r := &XrayClientRunner{config: cfg, observers: observers}
 	return r, r.start()
 }
 
 func (r *XrayClientRunner) start() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	cfg := r.config
+	// Port hopping: each (re)connect picks a random server port from the range.
+	if r.config.ServerPortRange != "" {
+		port, err := netutil.RandomPort(r.config.ServerPortRange)
+		if err != nil {
+			return err
+		}
+		hop := *r.config
+		hop.ServerPort = port
+		cfg = &hop
+		logger.Infof("using server port %d from range %s", port, r.config.ServerPortRange)
+	}
 	// Use config's local_port so Xray listens on the user-chosen port (no separate gate).
-	jsonBytes, err := BuildXrayClientJSON(r.config, r.config.LocalPort)
+	jsonBytes, err := BuildXrayClientJSON(cfg, cfg.LocalPort)
 	if err != nil {
 		return err
 	}
@@ -57,10 +80,23 @@ func (r *XrayClientRunner) start() error {
 	if err != nil {
 		return err
 	}
+	tracker := conntrack.NewTracker()
+	tracker.SetExactBytes(r.config.Metrics.Listen != "" || r.config.Metrics.Push.Address != "")
+	for _, o := range r.observers {
+		tracker.AddObserver(o)
+	}
+	for _, tag := range []string{"proxy", "direct"} {
+		if err := tracker.Wrap(instance, tag); err != nil {
+			_ = instance.Close()
+			return err
+		}
+	}
 	if err := instance.Start(); err != nil {
+		_ = instance.Close()
 		return err
 	}
 	r.instance = instance
+	r.tracker = tracker
 	return nil
 }
 
@@ -72,21 +108,46 @@ func (r *XrayClientRunner) Restart() error {
 		r.instance = nil
 	}
 	r.mu.Unlock()
+	r.restarts.Add(1)
 	return r.start()
 }
 
-// Close stops the Xray client instance.
+// Restarts returns how many times the tunnel was restarted (re-dialed).
+func (r *XrayClientRunner) Restarts() int64 {
+	return r.restarts.Load()
+}
+
+// Close stops accepting local connections, lets open ones finish for up to drain_timeout_seconds,
+// then stops the Xray client instance.
 func (r *XrayClientRunner) Close() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if r.instance == nil {
 		return nil
 	}
+	if timeout := time.Duration(r.config.DrainTimeoutSeconds) * time.Second; timeout > 0 {
+		if im, ok := r.instance.GetFeature(inbound.ManagerType()).(inbound.Manager); ok {
+			for _, tag := range localInboundTags(r.config) {
+				_ = im.RemoveHandler(context.Background(), tag)
+			}
+		}
+		if r.tracker.Active() > 0 {
+			forced := r.drainer.Run(timeout, r.tracker.Active, r.tracker.CloseAll, func(st netutil.DrainStatus) {
+				logger.Infof("draining: %d connections open, force-close in %s", st.Active, time.Until(st.Deadline).Round(time.Second))
+			})
+			logger.Infof("drain finished (%d connections force-closed)", forced)
+		}
+	}
 	err := r.instance.Close()
 	r.instance = nil
 	return err
 }
 
+// DrainStatus returns the progress of the current (or last) drain.
+func (r *XrayClientRunner) DrainStatus() netutil.DrainStatus {
+	return r.drainer.Status()
+}
+
 // Run keeps the runner alive until context is done.
 func (r *XrayClientRunner) Run(ctx context.Context) error {
 	<-ctx.Done()
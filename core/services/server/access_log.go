@@ -0,0 +1,189 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : access_log.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 19:08:26
+ * Description : Per-connection access log (user, source, destination, protocol, bytes, duration) with privacy modes.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// AccessLog is a tracker observer that writes one record when a connection closes (or is rejected).
+type AccessLog struct {
+	cfg  models.AccessLogConfig
+	out  *slog.Logger // nil = application logger
+	file io.Closer
+	salt []byte
+	mu   sync.Mutex
+}
+
+// checkAccessLog validates the privacy modes.
+func checkAccessLog(cfg *models.AccessLogConfig) error {
+	switch cfg.SourceIP {
+	case "", "full", "truncated", "hashed", "none":
+	default:
+		return fmt.Errorf("access_log.source_ip %q: must be full, truncated, hashed or none", cfg.SourceIP)
+	}
+	switch cfg.Destination {
+	case "", "full", "domain", "none":
+	default:
+		return fmt.Errorf("access_log.destination %q: must be full, domain or none", cfg.Destination)
+	}
+	return nil
+}
+
+// Apply switches to cfg; the log file (if any) is reopened with the rotation settings of logCfg.
+func (a *AccessLog) Apply(cfg *models.AccessLogConfig, logCfg *models.LogConfig) error {
+	if err := checkAccessLog(cfg); err != nil {
+		return err
+	}
+	var out *slog.Logger
+	var file io.Closer
+	if cfg.Enabled && cfg.File != "" {
+		var err error
+		if out, file, err = logger.NewFileLogger(cfg.File, logCfg.Format, "server", logger.RotateOptionsOf(logCfg)); err != nil {
+			return fmt.Errorf("access_log: %v", err)
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	salt := []byte(cfg.HashSalt)
+	if len(salt) == 0 {
+		// Keep the random salt across reloads so hashes stay comparable within one process.
+		salt = a.salt
+		if a.cfg.HashSalt != "" || salt == nil {
+			salt = make([]byte, 32)
+			_, _ = rand.Read(salt)
+		}
+	}
+	if a.file != nil {
+		_ = a.file.Close()
+	}
+	a.cfg, a.out, a.file, a.salt = *cfg, out, file, salt
+	return nil
+}
+
+// Close closes the access log file.
+func (a *AccessLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// Opened implements conntrack.Observer.
+func (a *AccessLog) Opened(c *conntrack.Conn) error {
+	return nil
+}
+
+// Closed implements conntrack.Observer.
+func (a *AccessLog) Closed(c *conntrack.Conn, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.cfg.Enabled {
+		return
+	}
+	attrs := []slog.Attr{slog.String("user", c.Email)}
+	if src := a.source(c.SourceIP); src != "" {
+		attrs = append(attrs, slog.String("src", src))
+	}
+	if dst := a.destination(c.Target); dst != "" {
+		attrs = append(attrs, slog.String("dst", dst))
+	}
+	proto := c.Protocol
+	if proto == "" {
+		proto = c.Network
+	}
+	attrs = append(attrs,
+		slog.String("proto", proto),
+		slog.String("inbound", c.Inbound),
+		slog.String("outbound", c.Outbound),
+		slog.Int64("up", c.Uplink()),
+		slog.Int64("down", c.Downlink()),
+		slog.Int64("duration_ms", time.Since(c.Started).Milliseconds()),
+	)
+	if err != nil {
+		attrs = append(attrs, slog.String("rejected", conntrack.ReasonOf(err)))
+	}
+	out := a.out
+	if out == nil {
+		out = logger.L()
+	}
+	out.LogAttrs(context.Background(), slog.LevelInfo, "connection", attrs...)
+}
+
+// source applies the source_ip privacy mode.
+func (a *AccessLog) source(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	switch a.cfg.SourceIP {
+	case "none":
+		return ""
+	case "hashed":
+		mac := hmac.New(sha256.New, a.salt)
+		mac.Write([]byte(ip))
+		return hex.EncodeToString(mac.Sum(nil))[:16]
+	case "truncated":
+		return truncateIP(ip)
+	}
+	return ip
+}
+
+// truncateIP keeps the /24 of IPv4 and the /48 of IPv6 addresses.
+func truncateIP(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// destination applies the destination privacy mode.
+func (a *AccessLog) destination(target string) string {
+	switch a.cfg.Destination {
+	case "none":
+		return ""
+	case "domain":
+		host, _, err := net.SplitHostPort(target)
+		if err != nil || net.ParseIP(host) != nil {
+			return ""
+		}
+		return host
+	}
+	return target
+}
@@ -0,0 +1,248 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : authbackend.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 23:52:17
+ * Description : Users pulled from an external HTTP auth backend, with the last good list cached on disk.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/userstore"
+	"github.com/ebrasha/abdal-gost-proxy/core/webhook"
+)
+
+// Auth backend defaults.
+const (
+	defaultAuthInterval  = time.Minute
+	defaultAuthTimeout   = 10 * time.Second
+	defaultAuthCachePath = "auth_backend_cache.json"
+	maxAuthResponse      = 32 << 20
+)
+
+// authCache is the on-disk form of the last good user list.
+type authCache struct {
+	URL       string              `json:"url"`
+	FetchedAt time.Time           `json:"fetched_at"`
+	Users     []models.ServerUser `json:"users"`
+}
+
+// authCachePath returns cache_path or its default.
+func authCachePath(cfg *models.AuthBackendConfig) string {
+	if cfg.CachePath != "" {
+		return cfg.CachePath
+	}
+	return defaultAuthCachePath
+}
+
+// checkAuthBackend validates the auth_backend section.
+func checkAuthBackend(cfg *models.AuthBackendConfig) error {
+	if cfg.URL == "" {
+		return nil
+	}
+	if err := webhook.Check(cfg.URL); err != nil {
+		return fmt.Errorf("auth_backend: %v", err)
+	}
+	if cfg.IntervalSeconds < 0 || cfg.TimeoutSeconds < 0 {
+		return fmt.Errorf("auth_backend: interval_seconds and timeout_seconds must not be negative")
+	}
+	return nil
+}
+
+// readAuthCache returns the cached users; a missing cache is an empty list.
+func readAuthCache(path string) ([]models.ServerUser, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth backend cache: %v", err)
+	}
+	var c authCache
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("auth backend cache %s: %v", path, err)
+	}
+	return c.Users, nil
+}
+
+// writeAuthCache replaces the cache file atomically.
+func writeAuthCache(path string, c *authCache) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// addBackendUsers appends the cached auth backend users (if configured) to cfg.Users.
+// Users whose email or id is already used locally are skipped, so local entries always win.
+func addBackendUsers(cfg *models.ServerConfig) error {
+	if cfg.AuthBackend.URL == "" {
+		return nil
+	}
+	users, err := readAuthCache(authCachePath(&cfg.AuthBackend))
+	if err != nil {
+		return err
+	}
+	emails := make(map[string]bool, len(cfg.Users))
+	ids := make(map[string]bool, len(cfg.Users))
+	for _, u := range cfg.Users {
+		emails[u.Email], ids[u.ID] = true, true
+	}
+	for _, u := range users {
+		if emails[u.Email] || ids[u.ID] {
+			logger.Warnf("auth backend: skipping %s, its email or id is already configured locally", u.Email)
+			continue
+		}
+		emails[u.Email], ids[u.ID] = true, true
+		cfg.Users = append(cfg.Users, u)
+	}
+	return nil
+}
+
+// fetchAuthUsers pulls and validates the user list from the backend.
+func fetchAuthUsers(ctx context.Context, cfg *models.AuthBackendConfig) ([]models.ServerUser, error) {
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultAuthTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "abdal-gost-proxy-auth")
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAuthResponse))
+	if err != nil {
+		return nil, err
+	}
+	var users []models.ServerUser
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("response is not a JSON array of users: %v", err)
+	}
+	emails := make(map[string]bool, len(users))
+	ids := make(map[string]bool, len(users))
+	for i := range users {
+		if err := userstore.Check(&users[i]); err != nil {
+			return nil, err
+		}
+		if emails[users[i].Email] || ids[users[i].ID] {
+			return nil, fmt.Errorf("user %s: duplicate email or id", users[i].Email)
+		}
+		emails[users[i].Email], ids[users[i].ID] = true, true
+	}
+	return users, nil
+}
+
+// SyncAuthBackend pulls the auth backend users every interval while auth_backend.url is set.
+// When the list changes it is validated, cached and onChange is called to reload the config;
+// while the backend is unreachable the cached list stays in effect.
+func (s *Server) SyncAuthBackend(ctx context.Context, onChange func()) {
+	failing := false
+	for {
+		s.mu.Lock()
+		cfg := s.cfg
+		s.mu.Unlock()
+		ab := cfg.AuthBackend
+		interval := time.Duration(ab.IntervalSeconds) * time.Second
+		if interval == 0 {
+			interval = defaultAuthInterval
+		}
+		if ab.URL != "" {
+			changed, err := s.syncAuthUsers(ctx, cfg)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil && !failing:
+				failing = true
+				logger.Warnf("auth backend %s: %v (keeping the last good user list)", ab.URL, err)
+			case err != nil:
+				logger.Debugf("auth backend %s: %v", ab.URL, err)
+			case failing:
+				failing = false
+				logger.Infof("auth backend %s: reachable again", ab.URL)
+			}
+			if changed {
+				onChange()
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// syncAuthUsers fetches the users once and updates the cache; changed reports whether the list differs from it.
+func (s *Server) syncAuthUsers(ctx context.Context, cfg *models.ServerConfig) (changed bool, err error) {
+	users, err := fetchAuthUsers(ctx, &cfg.AuthBackend)
+	if err != nil {
+		return false, err
+	}
+	path := authCachePath(&cfg.AuthBackend)
+	cached, err := readAuthCache(path)
+	if err != nil {
+		logger.Warnf("%v (replacing it)", err)
+	} else if (len(cached) == 0 && len(users) == 0) || reflect.DeepEqual(cached, users) {
+		return false, nil
+	}
+	// Validate the users (schedules, limits, Xray fields) before they can reach a reload.
+	probe := *cfg
+	probe.Users = users
+	if err := ValidateConfig(&probe); err != nil {
+		return false, err
+	}
+	if err := writeAuthCache(path, &authCache{URL: cfg.AuthBackend.URL, FetchedAt: time.Now().UTC(), Users: users}); err != nil {
+		return false, fmt.Errorf("writing cache: %v", err)
+	}
+	logger.Infof("auth backend: user list changed (%d users)", len(users))
+	return true, nil
+}
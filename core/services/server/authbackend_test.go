@@ -0,0 +1,208 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : authbackend_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 04:38:55
+ * Description : Tests for the auth backend sync: pulling users, the on-disk cache and falling back to it.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// testServerConfig returns a minimal valid server config with one local user.
+func testServerConfig(t *testing.T) *models.ServerConfig {
+	t.Helper()
+	const raw = `{
+		"listen_address": "127.0.0.1", "listen_port": 24443, "protocol": "vless",
+		"users": [{"id": "e3e96803-cb62-4bf0-8c5e-71cd02628430", "email": "admin@abdal"}],
+		"reality_settings": {"enabled": true, "dest": "127.0.0.1:18443", "server_names": ["www.google.com"],
+			"private_key": "HDuVNY8jkEkYJWKd0V2V6utGN-clr1J_bJr6292uOPg", "short_ids": ["1a2b3c4d5e6f"]},
+		"transport": {"type": "tcp"}
+	}`
+	var cfg models.ServerConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateConfig(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	return &cfg
+}
+
+// authStandIn serves the current user list to requests carrying the token and counts the pulls.
+type authStandIn struct {
+	users []models.ServerUser
+	pulls atomic.Int32
+	mu    sync.Mutex
+}
+
+func (a *authStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.pulls.Add(1)
+	if r.Header.Get("Authorization") != "Bearer t0ken" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(a.users)
+}
+
+func (a *authStandIn) set(users ...models.ServerUser) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.users = users
+}
+
+var (
+	backendAlice = models.ServerUser{ID: "0b9c4e52-5c1e-4d1f-9f52-0a3b8f7e6d01", Email: "alice@backend", MaxIPs: 2}
+	backendBob   = models.ServerUser{ID: "6f1d2a3b-7c8d-4e9f-8a0b-1c2d3e4f5a6b", Email: "bob@backend"}
+)
+
+// withAuthBackend points cfg at a stand-in backend with a cache in a temporary directory.
+func withAuthBackend(t *testing.T, cfg *models.ServerConfig) *authStandIn {
+	t.Helper()
+	backend := &authStandIn{}
+	srv := httptest.NewServer(backend)
+	t.Cleanup(srv.Close)
+	cfg.AuthBackend = models.AuthBackendConfig{URL: srv.URL, Token: "t0ken", CachePath: filepath.Join(t.TempDir(), "cache.json")}
+	return backend
+}
+
+func TestSyncAuthUsers(t *testing.T) {
+	cfg := testServerConfig(t)
+	backend := withAuthBackend(t, cfg)
+	s := &Server{cfg: cfg}
+	ctx := context.Background()
+
+	backend.set(backendAlice)
+	if changed, err := s.syncAuthUsers(ctx, cfg); err != nil || !changed {
+		t.Fatalf("first sync: changed=%v err=%v, want a change", changed, err)
+	}
+	if changed, err := s.syncAuthUsers(ctx, cfg); err != nil || changed {
+		t.Fatalf("same list: changed=%v err=%v, want no change", changed, err)
+	}
+	backend.set(backendAlice, backendBob)
+	if changed, err := s.syncAuthUsers(ctx, cfg); err != nil || !changed {
+		t.Fatalf("user added: changed=%v err=%v, want a change", changed, err)
+	}
+
+	// The cached users are served next to the local ones; a local email or id wins.
+	loaded := *cfg
+	loaded.Users = []models.ServerUser{{ID: "e3e96803-cb62-4bf0-8c5e-71cd02628430", Email: "admin@abdal"}, {ID: backendBob.ID, Email: "bob@local"}}
+	if err := addBackendUsers(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	var emails []string
+	for _, u := range loaded.Users {
+		emails = append(emails, u.Email)
+	}
+	if len(emails) != 3 || emails[2] != "alice@backend" || loaded.Users[2].MaxIPs != 2 {
+		t.Errorf("users after merge: %v", emails)
+	}
+
+	// Invalid lists never reach the cache.
+	backend.set(backendAlice, models.ServerUser{ID: "a1b2c3d4-0000-4000-8000-000000000000", Email: "alice@backend"})
+	if _, err := s.syncAuthUsers(ctx, cfg); err == nil {
+		t.Error("duplicate email accepted")
+	}
+	backend.set(models.ServerUser{ID: "not-a-uuid-at-all", Email: "x@backend", ExpiresAt: "soon"})
+	if _, err := s.syncAuthUsers(ctx, cfg); err == nil {
+		t.Error("invalid expires_at accepted")
+	}
+	if cached, err := readAuthCache(cfg.AuthBackend.CachePath); err != nil || len(cached) != 2 {
+		t.Errorf("cache after rejected lists: %d users, %v; want the last good 2", len(cached), err)
+	}
+}
+
+func TestAuthBackendUnreachable(t *testing.T) {
+	cfg := testServerConfig(t)
+	backend := withAuthBackend(t, cfg)
+	backend.set(backendAlice)
+	s := &Server{cfg: cfg}
+	if _, err := s.syncAuthUsers(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	wrongToken := *cfg
+	wrongToken.AuthBackend.Token = "wrong"
+	if _, err := s.syncAuthUsers(context.Background(), &wrongToken); err == nil {
+		t.Error("HTTP 401 accepted")
+	}
+	down := *cfg
+	down.AuthBackend.URL = "http://127.0.0.1:1/users"
+	down.AuthBackend.TimeoutSeconds = 1
+	if changed, err := s.syncAuthUsers(context.Background(), &down); err == nil || changed {
+		t.Errorf("unreachable backend: changed=%v err=%v", changed, err)
+	}
+
+	// A restart while the backend is down still serves the cached users.
+	restarted := down
+	restarted.Users = append([]models.ServerUser(nil), cfg.Users...)
+	if err := addBackendUsers(&restarted); err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.Users) != 2 || restarted.Users[1].Email != backendAlice.Email {
+		t.Errorf("users while the backend is down: %+v", restarted.Users)
+	}
+	if err := ValidateConfig(&restarted); err != nil {
+		t.Errorf("config with cached users: %v", err)
+	}
+}
+
+func TestSyncAuthBackendInterval(t *testing.T) {
+	cfg := testServerConfig(t)
+	backend := withAuthBackend(t, cfg)
+	cfg.AuthBackend.IntervalSeconds = 1
+	backend.set(backendAlice)
+	s := &Server{cfg: cfg}
+
+	var changes atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.SyncAuthBackend(ctx, func() { changes.Add(1) })
+	}()
+	waitFor(t, 3*time.Second, func() bool { return backend.pulls.Load() >= 2 })
+	if n := changes.Load(); n != 1 {
+		t.Errorf("%d reloads after pulling the same list twice, want 1", n)
+	}
+	backend.set(backendAlice, backendBob)
+	waitFor(t, 3*time.Second, func() bool { return changes.Load() == 2 })
+	cancel()
+	<-done
+}
+
+// waitFor polls cond until it holds or timeout passes.
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : bandwidth.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 21:06:12
+ * Description : Per-user upload/download rate limits shared by all connections of the user.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"golang.org/x/time/rate"
+)
+
+// minBurst is the smallest token bucket (bytes), so slow limits still pass whole buffers smoothly.
+const minBurst = 64 << 10
+
+// Throttler is a tracker observer that shapes the connections of rate-limited users.
+type Throttler struct {
+	users map[string]*userRates // by email
+	mu    sync.Mutex
+}
+
+// userRates are the token buckets of one user; connections keep them, so reloads reshape open connections too.
+type userRates struct {
+	up, down *rate.Limiter
+}
+
+// checkBandwidth rejects negative rates.
+func checkBandwidth(cfg *models.ServerConfig) error {
+	if cfg.Bandwidth.DefaultUploadMbps < 0 || cfg.Bandwidth.DefaultDownloadMbps < 0 {
+		return fmt.Errorf("bandwidth: rates must not be negative")
+	}
+	for _, u := range cfg.Users {
+		if u.UploadMbps < 0 || u.DownloadMbps < 0 {
+			return fmt.Errorf("user %s: upload_mbps / download_mbps must not be negative", u.Email)
+		}
+	}
+	return nil
+}
+
+// throttled reports whether any user is rate-limited.
+func throttled(cfg *models.ServerConfig) bool {
+	if cfg.Bandwidth.DefaultUploadMbps > 0 || cfg.Bandwidth.DefaultDownloadMbps > 0 {
+		return true
+	}
+	for _, u := range cfg.Users {
+		if u.UploadMbps > 0 || u.DownloadMbps > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// bandwidthChanged reports whether the default or any per-user rate differs between old and cfg.
+func bandwidthChanged(old, cfg *models.ServerConfig) bool {
+	if old.Bandwidth != cfg.Bandwidth {
+		return true
+	}
+	type rates struct{ up, down float64 }
+	before := make(map[string]rates, len(old.Users))
+	for _, u := range old.Users {
+		before[u.Email] = rates{u.UploadMbps, u.DownloadMbps}
+	}
+	for _, u := range cfg.Users {
+		if r, ok := before[u.Email]; ok && r != (rates{u.UploadMbps, u.DownloadMbps}) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply sets the rates from cfg, including for connections already open.
+func (t *Throttler) Apply(cfg *models.ServerConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.users == nil {
+		t.users = make(map[string]*userRates)
+	}
+	seen := make(map[string]bool, len(cfg.Users))
+	for _, u := range cfg.Users {
+		up, down := u.UploadMbps, u.DownloadMbps
+		if up == 0 {
+			up = cfg.Bandwidth.DefaultUploadMbps
+		}
+		if down == 0 {
+			down = cfg.Bandwidth.DefaultDownloadMbps
+		}
+		r, ok := t.users[u.Email]
+		if !ok {
+			r = &userRates{up: rate.NewLimiter(rate.Inf, 0), down: rate.NewLimiter(rate.Inf, 0)}
+			t.users[u.Email] = r
+		}
+		setMbps(r.up, up)
+		setMbps(r.down, down)
+		seen[u.Email] = true
+	}
+	for email, r := range t.users {
+		if !seen[email] {
+			// Removed users are unlimited until their connections close.
+			setMbps(r.up, 0)
+			setMbps(r.down, 0)
+			delete(t.users, email)
+		}
+	}
+}
+
+// setMbps sets l to mbps megabits per second (0 = unlimited) with a bucket of about one second.
+func setMbps(l *rate.Limiter, mbps float64) {
+	if mbps <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	bytes := mbps * 1e6 / 8
+	burst := int(bytes)
+	if burst < minBurst {
+		burst = minBurst
+	}
+	l.SetBurst(burst)
+	l.SetLimit(rate.Limit(bytes))
+}
+
+// Opened implements conntrack.Observer.
+func (t *Throttler) Opened(c *conntrack.Conn) error {
+	t.mu.Lock()
+	r := t.users[c.Email]
+	t.mu.Unlock()
+	if r != nil {
+		c.Throttle(r.up, r.down)
+	}
+	return nil
+}
+
+// Closed implements conntrack.Observer.
+func (t *Throttler) Closed(c *conntrack.Conn, err error) {}
@@ -0,0 +1,135 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : config_loader.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 12:58:33
+ * Description : Loads and validates abdal-gost-proxy-server.json (startup and hot reload).
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/metrics"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/userstore"
+	"github.com/xtls/xray-core/infra/conf/serial"
+)
+
+// LoadConfig reads and parses the server config file and validates it.
+func LoadConfig(path string) (*models.ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %v", path, err)
+	}
+	var cfg models.ServerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %v", err)
+	}
+	if err := addStoredUsers(&cfg); err != nil {
+		return nil, err
+	}
+	if err := checkAuthBackend(&cfg.AuthBackend); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	if err := addBackendUsers(&cfg); err != nil {
+		return nil, err
+	}
+	if err := ValidateConfig(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// addStoredUsers appends the users of the user store (if configured) to cfg.Users.
+func addStoredUsers(cfg *models.ServerConfig) error {
+	if cfg.UserStore.Path == "" {
+		return nil
+	}
+	var stored []userstore.User
+	err := userstore.With(cfg.UserStore.Path, func(st *userstore.Store) error {
+		var err error
+		stored, err = st.List()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	inline := make(map[string]bool, len(cfg.Users))
+	for _, u := range cfg.Users {
+		inline[u.Email] = true
+	}
+	for _, u := range stored {
+		if inline[u.Email] {
+			return fmt.Errorf("invalid config: user %s is both in users and in the user store", u.Email)
+		}
+		cfg.Users = append(cfg.Users, u.ServerUser)
+	}
+	return nil
+}
+
+// ValidateConfig builds the Xray config from cfg and lets Xray parse it, without starting anything.
+func ValidateConfig(cfg *models.ServerConfig) error {
+	jsonBytes, err := BuildXrayJSON(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if _, err := serial.LoadJSONConfig(bytes.NewReader(jsonBytes)); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := logger.Check(&cfg.Log); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := checkAccessLog(&cfg.AccessLog); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := checkGates(cfg); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := checkControl(&cfg.Control); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := checkIPLimit(cfg); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := checkBandwidth(cfg); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := checkSchedules(cfg); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := checkWebhooks(cfg); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := checkQuotas(cfg); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if err := checkAuthBackend(&cfg.AuthBackend); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if cfg.Metrics.Listen != "" {
+		if err := metrics.CheckListen(cfg.Metrics.Listen); err != nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+	}
+	if cfg.Metrics.Push.Address != "" {
+		if err := metrics.CheckPush(cfg.Metrics.Push.Address, cfg.Metrics.Push.Format); err != nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : control.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 19:58:14
+ * Description : Local control interface (loopback HTTP or unix socket, JSON) and its command-line client.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/netutil"
+	"github.com/ebrasha/abdal-gost-proxy/core/security"
+)
+
+// controlSampleInterval is how often connection throughput is measured while the control interface runs.
+const controlSampleInterval = 2 * time.Second
+
+// defaultControlSocketMode keeps the control socket private to the server's user.
+const defaultControlSocketMode = "0600"
+
+// controlServer serves the control endpoints:
+//
+//	GET  /connections[?user=email]  active sessions per user
+//	POST /kick?user=email           close every connection of a user
+//	GET  /drain                     drain progress
+type controlServer struct {
+	ln   net.Listener
+	srv  *http.Server
+	stop chan struct{}
+}
+
+// checkControl validates control.listen and control.token.
+func checkControl(cfg *models.ControlConfig) error {
+	if cfg.Listen == "" || security.IsUnixSocketPath(cfg.Listen) {
+		_, _, err := netutil.ParseSocketMode(cfg.UnixSocket.Mode)
+		return err
+	}
+	if err := netutil.CheckLoopback(cfg.Listen); err != nil {
+		return fmt.Errorf("control.listen %v", err)
+	}
+	// Any local process (or a web page through DNS rebinding) can reach a loopback port.
+	if cfg.Token == "" {
+		return fmt.Errorf("control.token is required when control.listen is a TCP address (or use a unix socket path)")
+	}
+	return nil
+}
+
+// controlListenerKey identifies the control socket for upgrade handover.
+func controlListenerKey(addr string) string {
+	if security.IsUnixSocketPath(addr) {
+		return listenerKey("unix", addr)
+	}
+	return listenerKey("tcp", addr)
+}
+
+// openControl listens on cfg.Listen (or an inherited listener) and serves the control endpoints of s.
+func (s *Server) openControl(cfg *models.ControlConfig) (*controlServer, error) {
+	ln, err := takeInheritedListener(controlListenerKey(cfg.Listen))
+	if err != nil {
+		return nil, err
+	}
+	mode := cfg.UnixSocket.Mode
+	if mode == "" {
+		mode = defaultControlSocketMode
+	}
+	switch {
+	case ln == nil && security.IsUnixSocketPath(cfg.Listen):
+		ln, err = netutil.ListenUnix(cfg.Listen, mode, cfg.UnixSocket.Owner)
+	case ln == nil:
+		ln, err = net.Listen("tcp", cfg.Listen)
+	case security.IsUnixSocketPath(cfg.Listen):
+		// Taken over: the mode or owner may have changed since the socket was created.
+		if err = netutil.SetSocketPerms(cfg.Listen, mode, cfg.UnixSocket.Owner); err != nil {
+			_ = ln.Close()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("control: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/connections", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.Connections(r.URL.Query().Get("user")))
+	})
+	mux.HandleFunc("/kick", func(w http.ResponseWriter, r *http.Request) {
+		user := r.URL.Query().Get("user")
+		if r.Method != http.MethodPost || user == "" {
+			http.Error(w, "POST /kick?user=<email>", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"user": user, "closed": s.Kick(user)})
+	})
+	mux.HandleFunc("/drain", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.DrainStatus())
+	})
+	c := &controlServer{ln: ln, srv: &http.Server{Handler: requireToken(cfg.Token, mux), ReadHeaderTimeout: 10 * time.Second}, stop: make(chan struct{})}
+	go func() { _ = c.srv.Serve(ln) }()
+	go c.sample(s)
+	return c, nil
+}
+
+// requireToken refuses requests without "Authorization: Bearer <token>"; an empty token lets every request through.
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "missing or wrong control token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// sample measures throughput until the control server is closed.
+func (c *controlServer) sample(s *Server) {
+	ticker := time.NewTicker(controlSampleInterval)
+	defer ticker.Stop()
+	for {
+		s.rates.sample(s.runner.Tracker().Conns())
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// File duplicates the listening socket (upgrade handover).
+func (c *controlServer) File() (*os.File, error) {
+	return netutil.ListenerFile(c.ln)
+}
+
+// Detach keeps a unix socket file on Close (the new process serves it after an upgrade).
+func (c *controlServer) Detach() {
+	netutil.KeepUnixSocket(c.ln)
+}
+
+// Close stops serving.
+func (c *controlServer) Close() error {
+	close(c.stop)
+	return c.srv.Close()
+}
+
+// controlClient returns an HTTP client and base URL for the control interface of cfg.
+func controlClient(cfg *models.ServerConfig) (*http.Client, string, error) {
+	addr := cfg.Control.Listen
+	if addr == "" {
+		return nil, "", fmt.Errorf("control.listen is not set in the config")
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if !security.IsUnixSocketPath(addr) {
+		return client, "http://" + addr, nil
+	}
+	client.Transport = &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", addr)
+	}}
+	return client, "http://control", nil
+}
+
+// callControl sends a request to the running server and decodes the JSON answer into out.
+func callControl(cfg *models.ServerConfig, method, path string, out interface{}) error {
+	client, base, err := controlClient(cfg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, base+path, nil)
+	if err != nil {
+		return err
+	}
+	if cfg.Control.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Control.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("control: %v (is the server running?)", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("control: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ListConnections asks the running server for its active sessions (all users when user is empty).
+func ListConnections(cfg *models.ServerConfig, user string) (*ConnectionsReport, error) {
+	var rep ConnectionsReport
+	path := "/connections"
+	if user != "" {
+		path += "?user=" + url.QueryEscape(user)
+	}
+	if err := callControl(cfg, http.MethodGet, path, &rep); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// KickUser asks the running server to close every connection of user; returns how many were closed.
+func KickUser(cfg *models.ServerConfig, user string) (int, error) {
+	var res struct {
+		Closed int `json:"closed"`
+	}
+	if err := callControl(cfg, http.MethodPost, "/kick?user="+url.QueryEscape(user), &res); err != nil {
+		return 0, err
+	}
+	return res.Closed, nil
+}
@@ -0,0 +1,77 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : control_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 05:46:09
+ * Description : Tests for the control interface token: required on TCP, checked on every endpoint.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+func TestCheckControlToken(t *testing.T) {
+	tests := []struct {
+		cfg models.ControlConfig
+		ok  bool
+	}{
+		{models.ControlConfig{Listen: "127.0.0.1:9090"}, false},
+		{models.ControlConfig{Listen: "127.0.0.1:9090", Token: "s3cret"}, true},
+		{models.ControlConfig{Listen: "/run/abdal/control.sock"}, true},
+		{models.ControlConfig{}, true},
+	}
+	for _, tt := range tests {
+		if err := checkControl(&tt.cfg); (err == nil) != tt.ok {
+			t.Errorf("%+v: err = %v", tt.cfg, err)
+		}
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	var served int
+	h := requireToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served++ }))
+	tests := []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", http.StatusUnauthorized},
+		{"Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		for _, req := range []*http.Request{httptest.NewRequest(http.MethodPost, "/kick?user=a@x", nil), httptest.NewRequest(http.MethodGet, "/connections", nil)} {
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("%s %s with %q: status %d, want %d", req.Method, req.URL.Path, tt.auth, rec.Code, tt.want)
+			}
+		}
+	}
+	if served != 2 {
+		t.Errorf("handler ran %d times, want 2", served)
+	}
+	rec := httptest.NewRecorder()
+	requireToken("", http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/drain", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without a token: status %d, want the handler's 404", rec.Code)
+	}
+}
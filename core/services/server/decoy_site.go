@@ -0,0 +1,295 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : decoy_site.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 10:12:40
+ * Description : Built-in decoy website (static dir or embedded template) served to fallback traffic.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/netutil"
+	"github.com/ebrasha/abdal-gost-proxy/core/security"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// DefaultDecoyListen is the decoy site address when decoy.listen is empty.
+const DefaultDecoyListen = "127.0.0.1:18080"
+
+//go:embed decoy_site/*
+var decoyTemplateFS embed.FS
+
+// decoyFile is one pre-loaded page of the decoy site.
+type decoyFile struct {
+	body    []byte
+	modTime time.Time
+	etag    string
+}
+
+// DecoySite serves a static website on loopback or a unix socket for fallback traffic.
+type DecoySite struct {
+	listen       string
+	serverHeader string
+	rootDir      string
+	builtin      map[string]*decoyFile
+	srv          *http.Server
+	ln           net.Listener
+	detached     bool // socket handed to a new process on upgrade
+	mu           sync.Mutex
+}
+
+// decoyListenAddr returns the configured decoy address or the default.
+func decoyListenAddr(cfg *models.DecoyConfig) string {
+	if cfg.Listen == "" {
+		return DefaultDecoyListen
+	}
+	return cfg.Listen
+}
+
+// NewDecoySite creates and starts the decoy site (listen and serve in background).
+func NewDecoySite(cfg *models.DecoyConfig) (*DecoySite, error) {
+	d := &DecoySite{
+		listen:       decoyListenAddr(cfg),
+		serverHeader: cfg.ServerHeader,
+		rootDir:      cfg.RootDir,
+	}
+	if d.serverHeader == "" {
+		d.serverHeader = "nginx"
+	}
+	if d.rootDir == "" {
+		files, err := renderDecoyTemplate(cfg.SiteName)
+		if err != nil {
+			return nil, err
+		}
+		d.builtin = files
+	} else if st, err := os.Stat(d.rootDir); err != nil || !st.IsDir() {
+		return nil, fmt.Errorf("decoy root_dir %s: not a directory", d.rootDir)
+	}
+	ln, err := listenDecoy(d.listen, &cfg.UnixSocket)
+	if err != nil {
+		return nil, err
+	}
+	// h2c lets fallbacks with ALPN "h2" reach the site as cleartext HTTP/2.
+	srv := &http.Server{
+		Handler:           h2c.NewHandler(http.HandlerFunc(d.serveHTTP), &http2.Server{}),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       75 * time.Second,
+	}
+	d.srv, d.ln = srv, ln
+	go func() { _ = srv.Serve(ln) }() // not d.srv: Close may clear it first
+	return d, nil
+}
+
+// listenDecoy listens on a unix socket path (with mode/owner) or TCP address, reusing an inherited listener after an upgrade.
+func listenDecoy(addr string, sock *models.UnixSocketConfig) (net.Listener, error) {
+	ln, err := takeInheritedListener(decoyListenerKey(addr))
+	if err != nil {
+		return nil, err
+	}
+	if ln != nil {
+		if security.IsUnixSocketPath(addr) {
+			// Taken over: the mode or owner may have changed since the socket was created.
+			if err := netutil.SetSocketPerms(addr, sock.Mode, sock.Owner); err != nil {
+				_ = ln.Close()
+				return nil, err
+			}
+		}
+		return ln, nil
+	}
+	if security.IsUnixSocketPath(addr) {
+		return netutil.ListenUnix(addr, sock.Mode, sock.Owner)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// decoyListenerKey identifies the decoy socket for upgrade handover.
+func decoyListenerKey(addr string) string {
+	if security.IsUnixSocketPath(addr) {
+		return listenerKey("unix", addr)
+	}
+	return listenerKey("tcp", addr)
+}
+
+// renderDecoyTemplate executes the embedded template pages once with the site name.
+func renderDecoyTemplate(siteName string) (map[string]*decoyFile, error) {
+	if siteName == "" {
+		siteName = "Northwind Systems"
+	}
+	data := struct {
+		SiteName string
+		Year     int
+	}{SiteName: siteName, Year: time.Now().Year()}
+	modTime := time.Now().UTC().Truncate(24 * time.Hour)
+	files := make(map[string]*decoyFile)
+	entries, err := fs.ReadDir(decoyTemplateFS, "decoy_site")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		raw, err := decoyTemplateFS.ReadFile("decoy_site/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		body := raw
+		if strings.HasSuffix(e.Name(), ".html") {
+			tpl, err := template.New(e.Name()).Parse(string(raw))
+			if err != nil {
+				return nil, err
+			}
+			var buf bytes.Buffer
+			if err := tpl.Execute(&buf, data); err != nil {
+				return nil, err
+			}
+			body = buf.Bytes()
+		}
+		files["/"+e.Name()] = &decoyFile{body: body, modTime: modTime, etag: nginxETag(modTime, int64(len(body)))}
+	}
+	return files, nil
+}
+
+// nginxETag formats an ETag the way nginx does for static files.
+func nginxETag(modTime time.Time, size int64) string {
+	return fmt.Sprintf("\"%x-%x\"", modTime.Unix(), size)
+}
+
+func (d *DecoySite) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("Server", d.serverHeader)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.Set("Allow", "GET, HEAD")
+		http.Error(w, "405 Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(name, "/") {
+		name += "index.html"
+	}
+	if d.builtin != nil {
+		d.serveBuiltin(w, r, name)
+		return
+	}
+	d.serveDir(w, r, name)
+}
+
+func (d *DecoySite) serveBuiltin(w http.ResponseWriter, r *http.Request, name string) {
+	f, ok := d.builtin[name]
+	if !ok || name == "/404.html" {
+		d.notFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", f.etag)
+	http.ServeContent(w, r, name, f.modTime, bytes.NewReader(f.body))
+}
+
+func (d *DecoySite) serveDir(w http.ResponseWriter, r *http.Request, name string) {
+	root := os.DirFS(d.rootDir)
+	rel := strings.TrimPrefix(name, "/")
+	st, err := fs.Stat(root, rel)
+	if err == nil && st.IsDir() {
+		rel = path.Join(rel, "index.html")
+		st, err = fs.Stat(root, rel)
+	}
+	if err != nil || st.IsDir() {
+		d.notFound(w, r)
+		return
+	}
+	f, err := root.Open(rel)
+	if err != nil {
+		d.notFound(w, r)
+		return
+	}
+	defer f.Close()
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		d.notFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", nginxETag(st.ModTime(), st.Size()))
+	http.ServeContent(w, r, st.Name(), st.ModTime(), rs)
+}
+
+// notFound writes the site's 404 page (404.html from the site, or a plain nginx-style page).
+func (d *DecoySite) notFound(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if d.builtin != nil {
+		if f, ok := d.builtin["/404.html"]; ok {
+			body = f.body
+		}
+	} else if b, err := os.ReadFile(path.Join(d.rootDir, "404.html")); err == nil {
+		body = b
+	}
+	if body == nil {
+		body = []byte("<html>\r\n<head><title>404 Not Found</title></head>\r\n<body>\r\n<center><h1>404 Not Found</h1></center>\r\n<hr><center>" + d.serverHeader + "</center>\r\n</body>\r\n</html>\r\n")
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusNotFound)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+// Addr returns the address the decoy site listens on.
+func (d *DecoySite) Addr() string {
+	return d.listen
+}
+
+// File duplicates the decoy socket for the new process.
+func (d *DecoySite) File() (*os.File, error) {
+	return netutil.ListenerFile(d.ln)
+}
+
+// Detach keeps the socket file on Close; the new process serves it from now on.
+func (d *DecoySite) Detach() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	netutil.KeepUnixSocket(d.ln)
+	d.detached = true
+}
+
+// Run blocks until ctx is done; then closes the site.
+func (d *DecoySite) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return d.Close()
+}
+
+// Close stops the decoy site.
+func (d *DecoySite) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.srv == nil {
+		return nil
+	}
+	err := d.srv.Close()
+	d.srv = nil
+	if security.IsUnixSocketPath(d.listen) && !netutil.IsAbstractSocket(d.listen) && !d.detached {
+		_ = os.Remove(d.listen)
+	}
+	return err
+}
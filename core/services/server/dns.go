@@ -0,0 +1,240 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : dns.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 01:31:44
+ * Description : Server-side DNS (UDP, TCP, DoT, DoH, DoQ upstreams, hosts, query strategy) used by freedom outbounds.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// dnsInboundTag is the inbound tag of Xray's own UDP DNS queries; a routing rule sends them direct.
+const dnsInboundTag = "dns-internal"
+
+const dotDialTimeout = 10 * time.Second
+
+type xrayDNS struct {
+	Servers       []xrayDNSServer   `json:"servers,omitempty"`
+	Hosts         map[string]string `json:"hosts,omitempty"`
+	QueryStrategy string            `json:"queryStrategy"`
+	DisableCache  bool              `json:"disableCache,omitempty"`
+	Tag           string            `json:"tag"`
+}
+
+type xrayDNSServer struct {
+	Address      string   `json:"address"`
+	Port         int      `json:"port,omitempty"`
+	Domains      []string `json:"domains,omitempty"`
+	SkipFallback bool     `json:"skipFallback,omitempty"`
+}
+
+// xrayFreedom is the settings of freedom outbounds when the server resolves through its DNS.
+type xrayFreedom struct {
+	DomainStrategy string `json:"domainStrategy"`
+}
+
+// dnsEnabled reports whether the dns section replaces the OS resolver.
+func dnsEnabled(cfg *models.DNSConfig) bool {
+	return len(cfg.Servers) > 0 || len(cfg.Hosts) > 0 || cfg.QueryStrategy != ""
+}
+
+// dnsStrategies returns the Xray DNS query strategy and freedom domain strategy of query_strategy.
+func dnsStrategies(s string) (query, freedom string, err error) {
+	switch s {
+	case "":
+		return "UseIP", "UseIP", nil
+	case "ipv4_only":
+		return "UseIPv4", "UseIPv4", nil
+	case "ipv6_only":
+		return "UseIPv6", "UseIPv6", nil
+	case "prefer_ipv4":
+		return "UseIP", "UseIPv4v6", nil
+	case "prefer_ipv6":
+		return "UseIP", "UseIPv6v4", nil
+	}
+	return "", "", fmt.Errorf("dns.query_strategy %q: must be ipv4_only, ipv6_only, prefer_ipv4 or prefer_ipv6", s)
+}
+
+// freedomSettings returns the settings of freedom outbounds (nil = resolve with the OS resolver).
+func freedomSettings(cfg *models.DNSConfig) interface{} {
+	if !dnsEnabled(cfg) {
+		return nil
+	}
+	_, freedom, _ := dnsStrategies(cfg.QueryStrategy)
+	return &xrayFreedom{DomainStrategy: freedom}
+}
+
+// dnsServerAddress converts an address from the dns section to Xray's form. Queries always leave from
+// this host ("+local"), except classic UDP, which Xray dispatches (see dnsInboundTag).
+// "tls://" is replaced by a local relay when the runner starts; until then it maps to plain TCP.
+func dnsServerAddress(addr string) (address string, port int, classic bool, err error) {
+	if addr == "localhost" {
+		return addr, 0, false, nil
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "udp://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil || u.Hostname() == "" {
+		return "", 0, false, fmt.Errorf("dns server %q: invalid address", addr)
+	}
+	withPort := func(def int) string {
+		if u.Port() != "" {
+			return u.Host
+		}
+		return net.JoinHostPort(u.Hostname(), strconv.Itoa(def))
+	}
+	switch u.Scheme {
+	case "udp":
+		if net.ParseIP(u.Hostname()) == nil {
+			return "", 0, false, fmt.Errorf("dns server %q: UDP servers must be IP addresses", addr)
+		}
+		port = 53
+		if u.Port() != "" {
+			port, _ = strconv.Atoi(u.Port())
+		}
+		return u.Hostname(), port, true, nil
+	case "tcp":
+		return "tcp+local://" + withPort(53), 0, false, nil
+	case "tls":
+		return "tcp+local://" + withPort(853), 0, false, nil
+	case "quic":
+		return "quic+local://" + withPort(853), 0, false, nil
+	case "https":
+		return "https+local://" + strings.TrimPrefix(addr, "https://"), 0, false, nil
+	case "tcp+local", "quic+local", "https+local":
+		return addr, 0, false, nil
+	}
+	return "", 0, false, fmt.Errorf("dns server %q: scheme must be udp, tcp, tls, https or quic", addr)
+}
+
+// buildDNS returns the Xray dns section and whether Xray sends UDP queries itself (nil when dns is not configured).
+func buildDNS(cfg *models.DNSConfig) (*xrayDNS, bool, error) {
+	if !dnsEnabled(cfg) {
+		return nil, false, nil
+	}
+	query, _, err := dnsStrategies(cfg.QueryStrategy)
+	if err != nil {
+		return nil, false, err
+	}
+	x := &xrayDNS{Hosts: cfg.Hosts, QueryStrategy: query, DisableCache: cfg.DisableCache, Tag: dnsInboundTag}
+	dispatched := false
+	for _, s := range cfg.Servers {
+		addr, port, classic, err := dnsServerAddress(s.Address)
+		if err != nil {
+			return nil, false, err
+		}
+		dispatched = dispatched || classic
+		x.Servers = append(x.Servers, xrayDNSServer{Address: addr, Port: port, Domains: s.Domains, SkipFallback: s.SkipFallback})
+	}
+	return x, dispatched, nil
+}
+
+// dotRelay forwards DNS-over-TCP from Xray on loopback to a DNS-over-TLS upstream (same framing).
+type dotRelay struct {
+	ln       net.Listener
+	upstream string // host:port
+	tlsCfg   *tls.Config
+	wg       sync.WaitGroup
+}
+
+// startDoTRelays starts a relay for every tls:// server of cfg and points the server at it.
+// cfg must be a copy owned by the caller; its dns.servers slice is replaced.
+func startDoTRelays(cfg *models.ServerConfig) ([]*dotRelay, error) {
+	var relays []*dotRelay
+	servers := append([]models.DNSServerConfig(nil), cfg.DNS.Servers...)
+	for i, s := range servers {
+		u, err := url.Parse(s.Address)
+		if err != nil || u.Scheme != "tls" {
+			continue
+		}
+		upstream := u.Host
+		if u.Port() == "" {
+			upstream = net.JoinHostPort(u.Hostname(), "853")
+		}
+		r, err := newDoTRelay(upstream, &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+		if err != nil {
+			closeDoTRelays(relays)
+			return nil, err
+		}
+		relays = append(relays, r)
+		servers[i].Address = "tcp+local://" + r.ln.Addr().String()
+	}
+	cfg.DNS.Servers = servers
+	return relays, nil
+}
+
+// newDoTRelay listens on a loopback port and relays its connections to upstream with tlsCfg.
+func newDoTRelay(upstream string, tlsCfg *tls.Config) (*dotRelay, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("dns relay for tls://%s: %v", upstream, err)
+	}
+	r := &dotRelay{ln: ln, upstream: upstream, tlsCfg: tlsCfg}
+	r.wg.Add(1)
+	go r.serve()
+	return r, nil
+}
+
+func (r *dotRelay) serve() {
+	defer r.wg.Done()
+	for {
+		c, err := r.ln.Accept()
+		if err != nil {
+			return
+		}
+		go r.relay(c)
+	}
+}
+
+// relay pipes one DNS-over-TCP connection to a new TLS connection to the upstream.
+func (r *dotRelay) relay(c net.Conn) {
+	defer c.Close()
+	d := &net.Dialer{Timeout: dotDialTimeout}
+	up, err := tls.DialWithDialer(d, "tcp", r.upstream, r.tlsCfg)
+	if err != nil {
+		logger.Warnf("dns: tls://%s: %v", r.upstream, err)
+		return
+	}
+	defer up.Close()
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(up, c)
+		_ = up.CloseWrite()
+		close(done)
+	}()
+	_, _ = io.Copy(c, up)
+	_ = c.Close()
+	<-done
+}
+
+func closeDoTRelays(relays []*dotRelay) {
+	for _, r := range relays {
+		_ = r.ln.Close()
+		r.wg.Wait()
+	}
+}
@@ -0,0 +1,252 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : dns_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 04:56:03
+ * Description : Tests for the dns section: server address schemes, hosts, query strategies and the DoT relay.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+func TestBuildDNSServers(t *testing.T) {
+	tests := []struct {
+		address    string
+		want       xrayDNSServer
+		dispatched bool
+		err        bool
+	}{
+		{address: "1.1.1.1", want: xrayDNSServer{Address: "1.1.1.1", Port: 53}, dispatched: true},
+		{address: "udp://8.8.8.8:5353", want: xrayDNSServer{Address: "8.8.8.8", Port: 5353}, dispatched: true},
+		{address: "udp://[2606:4700::1111]", want: xrayDNSServer{Address: "2606:4700::1111", Port: 53}, dispatched: true},
+		{address: "udp://dns.google", err: true},
+		{address: "tcp://1.1.1.1", want: xrayDNSServer{Address: "tcp+local://1.1.1.1:53"}},
+		{address: "tcp://[2606:4700::1111]:5353", want: xrayDNSServer{Address: "tcp+local://[2606:4700::1111]:5353"}},
+		{address: "tls://dns.google", want: xrayDNSServer{Address: "tcp+local://dns.google:853"}},
+		{address: "tls://1.1.1.1:8853", want: xrayDNSServer{Address: "tcp+local://1.1.1.1:8853"}},
+		{address: "https://dns.google/dns-query", want: xrayDNSServer{Address: "https+local://dns.google/dns-query"}},
+		{address: "quic://dns.adguard.com", want: xrayDNSServer{Address: "quic+local://dns.adguard.com:853"}},
+		{address: "https+local://1.1.1.1/dns-query", want: xrayDNSServer{Address: "https+local://1.1.1.1/dns-query"}},
+		{address: "localhost", want: xrayDNSServer{Address: "localhost"}},
+		{address: "ftp://1.1.1.1", err: true},
+		{address: "tcp://", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			cfg := &models.DNSConfig{Servers: []models.DNSServerConfig{{Address: tt.address, Domains: []string{"example.com"}, SkipFallback: true}}}
+			x, dispatched, err := buildDNS(cfg)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("accepted, got %+v", x.Servers)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Domains, tt.want.SkipFallback = []string{"example.com"}, true
+			if len(x.Servers) != 1 || !reflect.DeepEqual(x.Servers[0], tt.want) {
+				t.Errorf("servers = %+v, want %+v", x.Servers, tt.want)
+			}
+			if dispatched != tt.dispatched {
+				t.Errorf("dispatched = %v, want %v", dispatched, tt.dispatched)
+			}
+			if x.Tag != dnsInboundTag {
+				t.Errorf("tag = %q", x.Tag)
+			}
+		})
+	}
+}
+
+func TestBuildDNSStrategies(t *testing.T) {
+	tests := []struct {
+		strategy, query, freedom string
+	}{
+		{"", "UseIP", "UseIP"},
+		{"ipv4_only", "UseIPv4", "UseIPv4"},
+		{"ipv6_only", "UseIPv6", "UseIPv6"},
+		{"prefer_ipv4", "UseIP", "UseIPv4v6"},
+		{"prefer_ipv6", "UseIP", "UseIPv6v4"},
+	}
+	hosts := map[string]string{"router.lan": "192.168.1.1", "alias.lan": "router.lan"}
+	for _, tt := range tests {
+		cfg := &models.DNSConfig{Hosts: hosts, QueryStrategy: tt.strategy, DisableCache: true}
+		x, dispatched, err := buildDNS(cfg)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.strategy, err)
+		}
+		if x.QueryStrategy != tt.query || !reflect.DeepEqual(x.Hosts, hosts) || !x.DisableCache || dispatched || len(x.Servers) != 0 {
+			t.Errorf("%q: got %+v (dispatched %v)", tt.strategy, x, dispatched)
+		}
+		if f, ok := freedomSettings(cfg).(*xrayFreedom); !ok || f.DomainStrategy != tt.freedom {
+			t.Errorf("%q: freedom settings %+v, want %s", tt.strategy, freedomSettings(cfg), tt.freedom)
+		}
+	}
+	if _, _, err := buildDNS(&models.DNSConfig{QueryStrategy: "ipv4"}); err == nil {
+		t.Error("unknown query_strategy accepted")
+	}
+	if x, _, err := buildDNS(&models.DNSConfig{}); x != nil || err != nil || freedomSettings(&models.DNSConfig{}) != nil {
+		t.Errorf("empty dns section: %+v, %v; want the OS resolver", x, err)
+	}
+}
+
+// dotStandIn is a DNS-over-TLS server that answers every framed query with the query, QR bit set.
+func dotStandIn(t *testing.T) (addr string, roots *x509.CertPool) {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(nil) // only for its certificate (example.com, 127.0.0.1)
+	ts.StartTLS()
+	cert := ts.TLS.Certificates
+	roots = x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	ts.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				for {
+					msg, err := readFramed(c)
+					if err != nil {
+						return
+					}
+					msg[2] |= 0x80
+					if err := writeFramed(c, msg); err != nil {
+						return
+					}
+				}
+			}(c)
+		}
+	}()
+	return ln.Addr().String(), roots
+}
+
+func readFramed(r io.Reader) ([]byte, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, n)
+	_, err := io.ReadFull(r, msg)
+	return msg, err
+}
+
+func writeFramed(w io.Writer, msg []byte) error {
+	_, err := w.Write(binary.BigEndian.AppendUint16(nil, uint16(len(msg))))
+	if err == nil {
+		_, err = w.Write(msg)
+	}
+	return err
+}
+
+// query is a DNS query for example.com/A with id 0x1234.
+var query = []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0, 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0, 0, 1, 0, 1}
+
+func TestDoTRelay(t *testing.T) {
+	upstream, roots := dotStandIn(t)
+	r, err := newDoTRelay(upstream, &tls.Config{ServerName: "example.com", RootCAs: roots, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDoTRelays([]*dotRelay{r})
+
+	c, err := net.DialTimeout("tcp", r.ln.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	// Two queries on one connection, as Xray's DNS-over-TCP client sends them.
+	for i := 0; i < 2; i++ {
+		if err := writeFramed(c, query); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := readFramed(c)
+		if err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		want := append([]byte(nil), query...)
+		want[2] |= 0x80
+		if !bytes.Equal(resp, want) {
+			t.Errorf("query %d: response %x, want %x", i, resp, want)
+		}
+	}
+}
+
+func TestDoTRelayVerifiesUpstream(t *testing.T) {
+	upstream, _ := dotStandIn(t)
+	// The system roots do not trust the stand-in's certificate: the relay must drop the connection.
+	r, err := newDoTRelay(upstream, &tls.Config{ServerName: "example.com", MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDoTRelays([]*dotRelay{r})
+	c, err := net.DialTimeout("tcp", r.ln.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	_ = writeFramed(c, query)
+	if resp, err := readFramed(c); err == nil {
+		t.Errorf("got response %x through an unverified upstream", resp)
+	}
+}
+
+func TestStartDoTRelays(t *testing.T) {
+	cfg := &models.ServerConfig{DNS: models.DNSConfig{Servers: []models.DNSServerConfig{
+		{Address: "1.1.1.1"},
+		{Address: "tls://127.0.0.1:8853", Domains: []string{"example.com"}},
+		{Address: "https://dns.google/dns-query"},
+	}}}
+	orig := cfg.DNS.Servers
+	relays, err := startDoTRelays(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDoTRelays(relays)
+	if len(relays) != 1 || relays[0].upstream != "127.0.0.1:8853" || relays[0].tlsCfg.ServerName != "127.0.0.1" {
+		t.Fatalf("relays = %+v", relays)
+	}
+	got := cfg.DNS.Servers
+	if got[1].Address != "tcp+local://"+relays[0].ln.Addr().String() || !reflect.DeepEqual(got[1].Domains, []string{"example.com"}) {
+		t.Errorf("tls server rewritten to %+v", got[1])
+	}
+	if got[0].Address != "1.1.1.1" || !strings.HasPrefix(got[2].Address, "https://") {
+		t.Errorf("other servers changed: %+v", got)
+	}
+	if orig[1].Address != "tls://127.0.0.1:8853" {
+		t.Error("the caller's servers slice was modified")
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : drain_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 07:41:52
+ * Description : Tests for the shutdown drain: gRPC and TCP tunnels stay open while the server stops accepting.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/services/client"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/infra/conf/serial"
+)
+
+// freePort returns a loopback port that was free a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// echoServer echoes every line back.
+func echoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				_, _ = io.Copy(c, c)
+			}(c)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// tunnel starts an Xray client to the server on port over transport and opens one SOCKS5 connection to target through it.
+func tunnel(t *testing.T, port int, transport, target string) net.Conn {
+	t.Helper()
+	socksPort := freePort(t)
+	raw, err := client.BuildXrayClientJSON(&models.ClientConfig{
+		ServerAddr:       "127.0.0.1",
+		ServerPort:       port,
+		UUID:             "e3e96803-cb62-4bf0-8c5e-71cd02628430",
+		RealityPublicKey: "NlplsdGXg8x2vbAfHSuFLoqkJ7wgLscR8-ezLuQMJlY",
+		ShortID:          "1a2b3c4d5e6f",
+		SNI:              "www.google.com",
+		Transport:        transport,
+	}, socksPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xcfg, err := serial.LoadJSONConfig(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance, err := core.New(xcfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { instance.Close() })
+
+	c, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(socksPort), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+	host, p, _ := net.SplitHostPort(target)
+	ip, portNum := net.ParseIP(host).To4(), mustAtoi(t, p)
+	req := append([]byte{5, 1, 0, 5, 1, 0, 1}, ip...)
+	if _, err := c.Write(append(req, byte(portNum>>8), byte(portNum))); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 2+10)
+	if _, err := io.ReadFull(c, reply); err != nil || reply[3] != 0 {
+		t.Fatalf("socks5 %s: %x %v", transport, reply, err)
+	}
+	return c
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// roundTrip sends line through c and reports whether it came back.
+func roundTrip(c net.Conn, line string) bool {
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(c, line+"\n"); err != nil {
+		return false
+	}
+	got, err := bufio.NewReader(c).ReadString('\n')
+	return err == nil && got == line+"\n"
+}
+
+func TestDrainKeepsTunnels(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("drain is not supported on Windows")
+	}
+	t.Cleanup(removeInternalSockets)
+	echo := echoServer(t)
+	cfg := testServerConfig(t)
+	cfg.ListenPort = freePort(t)
+	grpcPort := freePort(t)
+	cfg.Inbounds = []models.InboundConfig{{
+		Tag: "grpc-in", ListenAddress: "127.0.0.1", ListenPort: grpcPort,
+		Transport: &models.TransportConfig{Type: "grpc", ServiceName: "abdal-grpc-stream"},
+	}}
+	cfg.Drain.TimeoutSeconds = 5
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewXrayRunner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	tcp := tunnel(t, cfg.ListenPort, "tcp", echo)
+	grpc := tunnel(t, grpcPort, "grpc", echo)
+	for name, c := range map[string]net.Conn{"tcp": tcp, "grpc": grpc} {
+		if !roundTrip(c, "before "+name) {
+			t.Fatalf("%s tunnel does not work", name)
+		}
+	}
+	if n := r.activeConns(); n != 2 {
+		t.Fatalf("%d connections before the drain, want 2", n)
+	}
+
+	r.stopAccepting(false)
+	for _, port := range []int{cfg.ListenPort, grpcPort} {
+		if c, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(port), time.Second); err == nil {
+			c.Close()
+			t.Errorf("port %d still accepts while draining", port)
+		}
+	}
+	for name, c := range map[string]net.Conn{"tcp": tcp, "grpc": grpc} {
+		if !roundTrip(c, "draining "+name) {
+			t.Errorf("%s tunnel closed by the drain", name)
+		}
+	}
+	if n := r.activeConns(); n != 2 {
+		t.Errorf("%d connections while draining, want 2", n)
+	}
+
+	if forced := r.drain(500*time.Millisecond, nil); forced != 2 {
+		t.Errorf("drain force-closed %d connections, want 2", forced)
+	}
+	for name, c := range map[string]net.Conn{"tcp": tcp, "grpc": grpc} {
+		if roundTrip(c, "after "+name) {
+			t.Errorf("%s tunnel still open after the drain deadline", name)
+		}
+	}
+}
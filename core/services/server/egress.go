@@ -0,0 +1,252 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : egress.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 00:24:09
+ * Description : Named outbounds (source IP, upstream proxy, next-hop Abdal server, block) and per-user / per-group egress routing.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/services/client"
+)
+
+// Built-in outbound tags; they always exist and cannot be redefined.
+const (
+	outboundDirect = "direct"
+	outboundBlock  = "block"
+)
+
+// xrayProxyServers is the settings of Xray socks / http outbounds.
+type xrayProxyServers struct {
+	Servers []xrayProxyServer `json:"servers"`
+}
+
+type xrayProxyServer struct {
+	Address string          `json:"address"`
+	Port    int             `json:"port"`
+	Users   []xrayProxyUser `json:"users,omitempty"`
+}
+
+type xrayProxyUser struct {
+	User string `json:"user"`
+	Pass string `json:"pass"`
+}
+
+// xrayRule is one routing rule of type "field".
+type xrayRule struct {
+	Type        string   `json:"type"`
+	InboundTag  []string `json:"inboundTag,omitempty"`
+	User        []string `json:"user,omitempty"`
+	Domain      []string `json:"domain,omitempty"`
+	Network     string   `json:"network,omitempty"`
+	Port        string   `json:"port,omitempty"`
+	Protocol    []string `json:"protocol,omitempty"`
+	OutboundTag string   `json:"outboundTag"`
+}
+
+// buildOutbounds returns the built-in outbounds followed by the configured ones.
+func buildOutbounds(cfg *models.ServerConfig) ([]xrayOutbound, error) {
+	out := []xrayOutbound{
+		{Protocol: "freedom", Tag: outboundDirect, Settings: freedomSettings(&cfg.DNS)},
+		{Protocol: "blackhole", Tag: outboundBlock},
+	}
+	seen := map[string]bool{outboundDirect: true, outboundBlock: true, outboundPolicyPort: true, outboundPolicyProtocol: true}
+	for i, o := range cfg.Outbounds {
+		if o.Tag == "" {
+			return nil, fmt.Errorf("outbounds[%d]: tag is required", i)
+		}
+		if seen[o.Tag] {
+			return nil, fmt.Errorf("outbounds[%d]: tag %q is reserved or used twice", i, o.Tag)
+		}
+		seen[o.Tag] = true
+		ob, err := buildOutbound(&o, &cfg.DNS)
+		if err != nil {
+			return nil, fmt.Errorf("outbound %s: %v", o.Tag, err)
+		}
+		out = append(out, ob)
+	}
+	if err := checkVia(cfg.Outbounds); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkVia validates the via chains of the outbounds: known, not blocking, and without loops.
+func checkVia(outbounds []models.OutboundConfig) error {
+	byTag := make(map[string]*models.OutboundConfig, len(outbounds))
+	for i := range outbounds {
+		byTag[outbounds[i].Tag] = &outbounds[i]
+	}
+	for _, o := range outbounds {
+		hops := 0
+		for via := o.Via; via != ""; via = byTag[via].Via {
+			next := byTag[via]
+			if next == nil {
+				return fmt.Errorf("outbound %s: via %q: unknown outbound (built-in outbounds cannot be used)", o.Tag, via)
+			}
+			if next.Type == "block" {
+				return fmt.Errorf("outbound %s: via %q: cannot go through a block outbound", o.Tag, via)
+			}
+			if hops++; hops > len(outbounds) {
+				return fmt.Errorf("outbound %s: via chain loops", o.Tag)
+			}
+		}
+	}
+	return nil
+}
+
+// checkNextHop validates the connection fields of an abdal outbound.
+func checkNextHop(c *models.ClientConfig) error {
+	switch {
+	case c == nil:
+		return fmt.Errorf("server is required")
+	case c.ServerAddr == "" || c.ServerPort < 1 || c.ServerPort > 65535:
+		return fmt.Errorf("server.server_addr and server.server_port are required")
+	case c.UUID == "" || c.RealityPublicKey == "" || c.SNI == "":
+		return fmt.Errorf("server.uuid, server.reality_public_key and server.sni are required")
+	case c.Transport != "" && c.Transport != "grpc" && c.Transport != "tcp":
+		return fmt.Errorf("server.transport %q: must be grpc or tcp", c.Transport)
+	}
+	return nil
+}
+
+// buildOutbound converts one configured outbound to Xray outbound JSON.
+func buildOutbound(o *models.OutboundConfig, dns *models.DNSConfig) (xrayOutbound, error) {
+	ob := xrayOutbound{Tag: o.Tag}
+	if o.SendThrough != "" {
+		if net.ParseIP(o.SendThrough) == nil {
+			return ob, fmt.Errorf("send_through %q: must be an IP address", o.SendThrough)
+		}
+		ob.SendThrough = o.SendThrough
+	}
+	switch o.Type {
+	case "", "direct":
+		ob.Protocol = "freedom"
+		ob.Settings = freedomSettings(dns)
+	case "block":
+		if o.SendThrough != "" {
+			return ob, fmt.Errorf("send_through is not used by type \"block\"")
+		}
+		ob.Protocol = "blackhole"
+	case "socks", "http":
+		host, portStr, err := net.SplitHostPort(o.Address)
+		port, perr := strconv.Atoi(portStr)
+		if err != nil || host == "" || perr != nil || port < 1 || port > 65535 {
+			return ob, fmt.Errorf("address %q: must be host:port", o.Address)
+		}
+		srv := xrayProxyServer{Address: host, Port: port}
+		if o.Username != "" {
+			srv.Users = []xrayProxyUser{{User: o.Username, Pass: o.Password}}
+		}
+		ob.Protocol = o.Type
+		ob.Settings = &xrayProxyServers{Servers: []xrayProxyServer{srv}}
+	case "abdal":
+		if err := checkNextHop(o.Server); err != nil {
+			return ob, err
+		}
+		settings, stream := client.BuildProxyOutbound(o.Server)
+		ob.Protocol = "vless"
+		ob.Settings, ob.StreamSettings = settings, stream
+	default:
+		return ob, fmt.Errorf("type %q: must be direct, socks, http, abdal or block", o.Type)
+	}
+	if o.Via != "" {
+		if o.Type == "block" {
+			return ob, fmt.Errorf("via is not used by type \"block\"")
+		}
+		ob.ProxySettings = &xrayProxySettings{Tag: o.Via, TransportLayer: true}
+	}
+	return ob, nil
+}
+
+// userOutbound returns the outbound of u: its own, its group's, or egress.default_outbound.
+func userOutbound(cfg *models.ServerConfig, u *models.ServerUser) string {
+	if u.Outbound != "" {
+		return u.Outbound
+	}
+	if tag := cfg.Egress.Groups[u.Group]; u.Group != "" && tag != "" {
+		return tag
+	}
+	return cfg.Egress.DefaultOutbound
+}
+
+// buildEgressRules returns one rule per outbound that users are routed to, plus a catch-all for
+// egress.default_outbound. Users of the default outbound need no rule of their own.
+func buildEgressRules(cfg *models.ServerConfig, outbounds []xrayOutbound) ([]xrayRule, error) {
+	known := make(map[string]bool, len(outbounds))
+	for _, o := range outbounds {
+		known[o.Tag] = true
+	}
+	check := func(what, tag string) error {
+		if tag != "" && !known[tag] {
+			return fmt.Errorf("%s: unknown outbound %q", what, tag)
+		}
+		return nil
+	}
+	if err := check("egress.default_outbound", cfg.Egress.DefaultOutbound); err != nil {
+		return nil, err
+	}
+	for group, tag := range cfg.Egress.Groups {
+		if err := check("egress.groups."+group, tag); err != nil {
+			return nil, err
+		}
+	}
+	def := cfg.Egress.DefaultOutbound
+	if def == "" {
+		def = outboundDirect
+	}
+	byTag := make(map[string][]string)
+	for i := range cfg.Users {
+		u := &cfg.Users[i]
+		if err := check("user "+u.Email, u.Outbound); err != nil {
+			return nil, err
+		}
+		if tag := userOutbound(cfg, u); tag != "" && tag != def {
+			byTag[tag] = append(byTag[tag], u.Email)
+		}
+	}
+	tags := make([]string, 0, len(byTag))
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags) // stable rules, so unchanged configs do not trigger a routing reload
+	var rules []xrayRule
+	for _, tag := range tags {
+		rules = append(rules, xrayRule{Type: "field", User: byTag[tag], OutboundTag: tag})
+	}
+	if def != outboundDirect {
+		rules = append(rules, xrayRule{Type: "field", Network: "tcp,udp", OutboundTag: def})
+	}
+	return rules, nil
+}
+
+// marshalRules encodes routing rules for xrayRouting.
+func marshalRules(rules []xrayRule) ([]json.RawMessage, error) {
+	raw := make([]json.RawMessage, 0, len(rules))
+	for _, r := range rules {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, b)
+	}
+	return raw, nil
+}
@@ -0,0 +1,291 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : inbounds.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 11:40:27
+ * Description : Resolves the root inbound and extra inbounds (ports, port ranges, users, Reality) from server config.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"strconv"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/netutil"
+	"github.com/ebrasha/abdal-gost-proxy/core/security"
+	"github.com/ebrasha/abdal-gost-proxy/core/services/client"
+)
+
+// primaryInboundTag is the Xray tag of the inbound declared by the root listen_address/listen_port.
+const primaryInboundTag = "vless-in"
+
+// resolvedInbound is one VLESS inbound with root settings merged into a flat ServerConfig.
+type resolvedInbound struct {
+	tag       string
+	portRange string
+	cfg       models.ServerConfig
+}
+
+// isUnix reports whether the inbound listens on a unix domain socket.
+func (in *resolvedInbound) isUnix() bool {
+	return security.IsUnixSocketPath(in.cfg.ListenAddress)
+}
+
+// listen returns the Xray "listen" value (socket paths carry the permission suffix).
+func (in *resolvedInbound) listen() (string, error) {
+	if in.isUnix() {
+		return netutil.XrayListenAddr(in.cfg.ListenAddress, in.cfg.UnixSocket.Mode)
+	}
+	return in.cfg.ListenAddress, nil
+}
+
+// displayAddr returns the listen address for log lines.
+func (in *resolvedInbound) displayAddr() string {
+	switch {
+	case in.isUnix():
+		return "unix:" + in.cfg.ListenAddress
+	case in.portRange != "":
+		return in.cfg.ListenAddress + ":" + in.portRange
+	}
+	return net.JoinHostPort(in.cfg.ListenAddress, strconv.Itoa(in.cfg.ListenPort))
+}
+
+// port returns the Xray "port" value: the range string for port hopping, nil for unix sockets, else the single port.
+func (in *resolvedInbound) port() interface{} {
+	if in.isUnix() {
+		return nil
+	}
+	if in.portRange != "" {
+		return in.portRange
+	}
+	return in.cfg.ListenPort
+}
+
+// ports lists every port the inbound listens on.
+func (in *resolvedInbound) ports() []int {
+	if in.portRange == "" {
+		return []int{in.cfg.ListenPort}
+	}
+	from, to, _ := netutil.ParsePortRange(in.portRange)
+	out := make([]int, 0, to-from+1)
+	for p := from; p <= to; p++ {
+		out = append(out, p)
+	}
+	return out
+}
+
+// resolveInbounds returns the root inbound (when listen_port is set) followed by every entry of cfg.Inbounds.
+func resolveInbounds(cfg *models.ServerConfig) ([]resolvedInbound, error) {
+	out := make([]resolvedInbound, 0, len(cfg.Inbounds)+1)
+	if cfg.ListenPort > 0 || security.IsUnixSocketPath(cfg.ListenAddress) || len(cfg.Inbounds) == 0 {
+		out = append(out, resolvedInbound{tag: primaryInboundTag, cfg: *cfg})
+	}
+	seen := map[string]bool{primaryInboundTag: true}
+	for i, ic := range cfg.Inbounds {
+		tag := tagOf(&ic, i)
+		if seen[tag] {
+			return nil, fmt.Errorf("inbounds[%d]: duplicate tag %q", i, tag)
+		}
+		seen[tag] = true
+		in := resolvedInbound{tag: tag, portRange: ic.PortRange, cfg: *cfg}
+		in.cfg.Inbounds = nil
+		if ic.ListenAddress != "" {
+			in.cfg.ListenAddress = ic.ListenAddress
+		}
+		in.cfg.ListenPort = ic.ListenPort
+		if ic.UnixSocket != nil {
+			in.cfg.UnixSocket = *ic.UnixSocket
+		}
+		if in.isUnix() {
+			in.portRange = ""
+			in.cfg.ListenPort = 0
+		} else if ic.PortRange != "" {
+			if _, _, err := netutil.ParsePortRange(ic.PortRange); err != nil {
+				return nil, fmt.Errorf("inbounds[%d]: %v", i, err)
+			}
+			in.cfg.ListenPort = 0
+		} else if ic.ListenPort <= 0 {
+			return nil, fmt.Errorf("inbounds[%d]: listen_port or port_range is required", i)
+		}
+		if ic.Transport != nil {
+			in.cfg.Transport = *ic.Transport
+		}
+		if ic.RealitySettings != nil {
+			in.cfg.RealitySettings = *ic.RealitySettings
+		}
+		if ic.Fallbacks != nil {
+			in.cfg.Fallbacks = ic.Fallbacks
+		}
+		if ic.ProxyProtocol != nil {
+			in.cfg.ProxyProtocol = *ic.ProxyProtocol
+		}
+		if len(ic.UserEmails) > 0 {
+			users, err := selectUsers(cfg.Users, ic.UserEmails)
+			if err != nil {
+				return nil, fmt.Errorf("inbounds[%d]: %v", i, err)
+			}
+			in.cfg.Users = users
+		}
+		out = append(out, in)
+	}
+	return out, nil
+}
+
+// selectUsers returns the users whose email is listed, in the order of emails.
+func selectUsers(users []models.ServerUser, emails []string) ([]models.ServerUser, error) {
+	byEmail := make(map[string]models.ServerUser, len(users))
+	for _, u := range users {
+		byEmail[u.Email] = u
+	}
+	out := make([]models.ServerUser, 0, len(emails))
+	for _, e := range emails {
+		u, ok := byEmail[e]
+		if !ok {
+			return nil, fmt.Errorf("user_emails: unknown user %q", e)
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// gateAll reports whether every inbound needs a gate (upgrade handover and drain own the public sockets:
+// closing a gate stops accepting without tearing down Xray's transport hubs and the tunnels they carry).
+// Upgrades are not supported on Windows, so upgrade.enabled is ignored there.
+func gateAll(cfg *models.ServerConfig) bool {
+	return (cfg.Upgrade.Enabled && runtime.GOOS != "windows") || cfg.Drain.TimeoutSeconds > 0
+}
+
+// checkGates rejects what needs a gate on Windows: Xray cannot listen on unix sockets there, and a loopback
+// port for the internal hop could be taken, or sent forged PROXY headers, by any local process.
+func checkGates(cfg *models.ServerConfig) error {
+	if runtime.GOOS != "windows" {
+		return nil
+	}
+	if cfg.Drain.TimeoutSeconds > 0 {
+		return fmt.Errorf("drain.timeout_seconds is not supported on Windows")
+	}
+	resolved, err := resolveInbounds(cfg)
+	if err != nil {
+		return err
+	}
+	for i := range resolved {
+		if len(resolved[i].cfg.ProxyProtocol.TrustedSources) > 0 {
+			return fmt.Errorf("inbound %s: proxy_protocol.trusted_sources is not supported on Windows", resolved[i].tag)
+		}
+	}
+	return nil
+}
+
+// planGates moves every inbound that trusts PROXY protocol sources (or, with upgrade/drain enabled, every inbound) onto an
+// internal listener (see internalListenAddr) and returns the rewritten config plus one gate per public port or socket
+// (with upgrade/drain enabled, reverse tunnel listeners too).
+func planGates(cfg *models.ServerConfig) (*models.ServerConfig, []gatePlan, error) {
+	resolved, err := resolveInbounds(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := *cfg
+	out.Inbounds = append([]models.InboundConfig(nil), cfg.Inbounds...)
+	// Pin inherited settings before the root ones may move to loopback.
+	for j := range out.Inbounds {
+		ic := &out.Inbounds[j]
+		if ic.ListenAddress == "" {
+			ic.ListenAddress = cfg.ListenAddress
+		}
+		if ic.ProxyProtocol == nil {
+			pp := cfg.ProxyProtocol
+			ic.ProxyProtocol = &pp
+		}
+		if ic.UnixSocket == nil {
+			us := cfg.UnixSocket
+			ic.UnixSocket = &us
+		}
+	}
+	var plans []gatePlan
+	for i := range resolved {
+		in := &resolved[i]
+		pp := in.cfg.ProxyProtocol
+		// Unix sockets are local-only; without a gate Xray reads their PROXY header directly.
+		trustedGate := pp.Enabled && len(pp.TrustedSources) > 0 && !in.isUnix()
+		if !trustedGate && !gateAll(cfg) {
+			continue
+		}
+		plan := gatePlan{tag: in.tag, network: "tcp", acceptHeader: pp.Enabled}
+		if trustedGate {
+			trusted, err := security.ParseTrustedSources(pp.TrustedSources)
+			if err != nil {
+				return nil, nil, fmt.Errorf("inbound %s: %v", in.tag, err)
+			}
+			plan.trusted = trusted
+		}
+		internal, err := internalListenAddr()
+		if err != nil {
+			return nil, nil, err
+		}
+		plan.internalAddr = internal
+		if in.isUnix() {
+			plan.network = "unix"
+			plan.listenAddr = in.cfg.ListenAddress
+			plan.unixSocket = in.cfg.UnixSocket
+			plans = append(plans, plan)
+		} else {
+			for _, p := range in.ports() {
+				plan.listenAddr = net.JoinHostPort(in.cfg.ListenAddress, strconv.Itoa(p))
+				plans = append(plans, plan)
+			}
+		}
+		host, port := internalListen(internal)
+		if in.tag == primaryInboundTag {
+			out.ListenAddress = host
+			out.ListenPort = port
+			out.ProxyProtocol.Enabled = true
+			continue
+		}
+		for j := range out.Inbounds {
+			if tagOf(&out.Inbounds[j], j) == in.tag {
+				out.Inbounds[j].ListenAddress = host
+				out.Inbounds[j].ListenPort = port
+				out.Inbounds[j].PortRange = ""
+				out.Inbounds[j].ProxyProtocol = &models.ProxyProtocolConfig{Enabled: true}
+			}
+		}
+	}
+	if gateAll(cfg) {
+		// Reverse tunnel listeners are handed over and drained like inbounds; their gates dispatch into Xray directly.
+		for _, rt := range cfg.Reverse {
+			plans = append(plans, gatePlan{tag: client.ReverseTag(rt.Name), network: "tcp", listenAddr: rt.Listen})
+		}
+	}
+	return &out, plans, nil
+}
+
+// internalListen splits an internal listener address into the listen_address and listen_port of an inbound.
+func internalListen(addr string) (string, int) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, 0 // socket path
+	}
+	n, _ := strconv.Atoi(port)
+	return host, n
+}
+
+// tagOf returns the effective tag of cfg.Inbounds[i].
+func tagOf(ic *models.InboundConfig, i int) string {
+	if ic.Tag != "" {
+		return ic.Tag
+	}
+	return primaryInboundTag + "-" + strconv.Itoa(i+1)
+}
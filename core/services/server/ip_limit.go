@@ -0,0 +1,187 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : ip_limit.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 20:31:09
+ * Description : Per-user limit on distinct simultaneous source IPs (reject newest or kick oldest).
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/webhook"
+)
+
+// IP limit policies.
+const (
+	ipPolicyRejectNewest = "reject_newest"
+	ipPolicyKickOldest   = "kick_oldest"
+)
+
+// IPLimiter is a tracker observer that enforces max_ips per user.
+type IPLimiter struct {
+	limits map[string]int // by user email
+	policy string
+	users  map[string]map[string]*ipEntry // email -> source IP -> open connections
+	conns  map[*conntrack.Conn]*ipEntry
+	hooks  *webhook.Dispatcher // optional
+	mu     sync.Mutex
+}
+
+// ipEntry holds the open connections of one user from one source IP.
+type ipEntry struct {
+	ip     string
+	since  time.Time
+	conns  map[*conntrack.Conn]struct{}
+	kicked bool
+}
+
+// checkIPLimit validates the policy and limits.
+func checkIPLimit(cfg *models.ServerConfig) error {
+	switch cfg.IPLimit.Policy {
+	case "", ipPolicyRejectNewest, ipPolicyKickOldest:
+	default:
+		return fmt.Errorf("ip_limit.policy %q: must be %s or %s", cfg.IPLimit.Policy, ipPolicyRejectNewest, ipPolicyKickOldest)
+	}
+	if cfg.IPLimit.DefaultMaxIPs < 0 {
+		return fmt.Errorf("ip_limit.default_max_ips must not be negative")
+	}
+	for _, u := range cfg.Users {
+		if u.MaxIPs < 0 {
+			return fmt.Errorf("user %s: max_ips must not be negative", u.Email)
+		}
+	}
+	return nil
+}
+
+// Apply sets the limits from cfg; connections already open are not affected.
+func (l *IPLimiter) Apply(cfg *models.ServerConfig) {
+	limits := make(map[string]int, len(cfg.Users))
+	for _, u := range cfg.Users {
+		max := u.MaxIPs
+		if max == 0 {
+			max = cfg.IPLimit.DefaultMaxIPs
+		}
+		if max > 0 {
+			limits[u.Email] = max
+		}
+	}
+	policy := cfg.IPLimit.Policy
+	if policy == "" {
+		policy = ipPolicyRejectNewest
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits, l.policy = limits, policy
+	if l.users == nil {
+		l.users = make(map[string]map[string]*ipEntry)
+		l.conns = make(map[*conntrack.Conn]*ipEntry)
+	}
+}
+
+// Opened implements conntrack.Observer.
+func (l *IPLimiter) Opened(c *conntrack.Conn) error {
+	if c.Email == "" || c.SourceIP == "" {
+		return nil
+	}
+	l.mu.Lock()
+	ips := l.users[c.Email]
+	if ips == nil {
+		ips = make(map[string]*ipEntry)
+		l.users[c.Email] = ips
+	}
+	e, ok := ips[c.SourceIP]
+	max := l.limits[c.Email] // read under the lock: Apply replaces the map
+	var kicked *ipEntry
+	if !ok {
+		if n := len(ips); max > 0 && n >= max {
+			if l.policy != ipPolicyKickOldest {
+				l.mu.Unlock()
+				logger.Warnf("ip limit: %s already uses %d source IPs (max %d); rejected %s", c.Email, n, max, c.SourceIP)
+				l.notify(c, max, "rejected", "")
+				return conntrack.Reject("ip_limit", "%s: max %d source IPs", c.Email, max)
+			}
+			kicked = oldestEntry(ips)
+			kicked.kicked = true
+			delete(ips, kicked.ip)
+		}
+		e = &ipEntry{ip: c.SourceIP, since: time.Now(), conns: make(map[*conntrack.Conn]struct{})}
+		ips[c.SourceIP] = e
+	}
+	e.conns[c] = struct{}{}
+	l.conns[c] = e
+	var toClose []*conntrack.Conn
+	if kicked != nil {
+		for kc := range kicked.conns {
+			toClose = append(toClose, kc)
+		}
+	}
+	l.mu.Unlock()
+	if kicked != nil {
+		logger.Warnf("ip limit: %s connected from new source IP %s; kicked oldest %s (%d connections)", c.Email, c.SourceIP, kicked.ip, len(toClose))
+		l.notify(c, max, "kicked_oldest", kicked.ip)
+		for _, kc := range toClose {
+			kc.Close()
+		}
+	}
+	return nil
+}
+
+// Closed implements conntrack.Observer.
+func (l *IPLimiter) Closed(c *conntrack.Conn, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.conns[c]
+	if !ok {
+		return
+	}
+	delete(l.conns, c)
+	delete(e.conns, c)
+	if len(e.conns) > 0 || e.kicked {
+		return
+	}
+	if ips := l.users[c.Email]; ips[e.ip] == e {
+		delete(ips, e.ip)
+		if len(ips) == 0 {
+			delete(l.users, c.Email)
+		}
+	}
+}
+
+// notify sends a connection_limit webhook event (at most one per user and minute).
+func (l *IPLimiter) notify(c *conntrack.Conn, max int, action, kickedIP string) {
+	if l.hooks == nil {
+		return
+	}
+	data := map[string]interface{}{"user": c.Email, "source_ip": c.SourceIP, "max_ips": max, "action": action}
+	if kickedIP != "" {
+		data["kicked_ip"] = kickedIP
+	}
+	l.hooks.NotifyEvery(eventConnectionLimit+" "+c.Email, connectionLimitEvery, eventConnectionLimit, data)
+}
+
+func oldestEntry(ips map[string]*ipEntry) *ipEntry {
+	var oldest *ipEntry
+	for _, e := range ips {
+		if oldest == nil || e.since.Before(oldest.since) {
+			oldest = e
+		}
+	}
+	return oldest
+}
@@ -0,0 +1,158 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : ip_limit_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 05:14:37
+ * Description : Tests for the per-user source IP limit: reject_newest, kick_oldest and reloads while in use.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/signal/done"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+// testConn is a tracked connection of email from ip whose link reports when the connection was closed.
+type testConn struct {
+	*conntrack.Conn
+	reader *pipe.Reader
+}
+
+func newTestConn(email, ip string) *testConn {
+	r, w := pipe.New()
+	c := conntrack.NewConn(context.Background(), "direct", &transport.Link{Reader: r, Writer: w})
+	c.Email, c.SourceIP = email, ip
+	return &testConn{Conn: c, reader: r}
+}
+
+// closed reports whether the connection was interrupted (kicked).
+func (c *testConn) closed() bool {
+	_, err := c.reader.ReadMultiBufferTimeout(10 * time.Millisecond)
+	return err != nil && err != buf.ErrReadTimeout
+}
+
+func ipLimitConfig(policy string, defaultMax int, users ...models.ServerUser) *models.ServerConfig {
+	return &models.ServerConfig{Users: users, IPLimit: models.IPLimitConfig{Policy: policy, DefaultMaxIPs: defaultMax}}
+}
+
+func TestIPLimitRejectNewest(t *testing.T) {
+	var l IPLimiter
+	l.Apply(ipLimitConfig("", 0, models.ServerUser{Email: "a@x", MaxIPs: 2}, models.ServerUser{Email: "free@x"}))
+
+	first := newTestConn("a@x", "10.0.0.1")
+	for _, c := range []*testConn{first, newTestConn("a@x", "10.0.0.2"), newTestConn("a@x", "10.0.0.1")} {
+		if err := l.Opened(c.Conn); err != nil {
+			t.Fatalf("%s: %v", c.SourceIP, err)
+		}
+	}
+	third := newTestConn("a@x", "10.0.0.3")
+	err := l.Opened(third.Conn)
+	if err == nil || conntrack.ReasonOf(err) != "ip_limit" {
+		t.Fatalf("third source IP: err = %v, want an ip_limit rejection", err)
+	}
+	l.Closed(third.Conn, err)
+	if first.closed() {
+		t.Error("reject_newest closed an existing connection")
+	}
+	for i := 0; i < 5; i++ {
+		if err := l.Opened(newTestConn("free@x", "10.1.0."+strconv.Itoa(i)).Conn); err != nil {
+			t.Errorf("user without a limit: %v", err)
+		}
+	}
+
+	// Once every connection of 10.0.0.1 is closed, its slot is free.
+	for c, e := range l.conns {
+		if e.ip == "10.0.0.1" {
+			l.Closed(c, nil)
+		}
+	}
+	if err := l.Opened(newTestConn("a@x", "10.0.0.3").Conn); err != nil {
+		t.Errorf("after 10.0.0.1 left: %v", err)
+	}
+}
+
+func TestIPLimitKickOldest(t *testing.T) {
+	var l IPLimiter
+	l.Apply(ipLimitConfig(ipPolicyKickOldest, 1, models.ServerUser{Email: "a@x"}))
+
+	old1, old2 := newTestConn("a@x", "10.0.0.1"), newTestConn("a@x", "10.0.0.1")
+	for _, c := range []*testConn{old1, old2} {
+		if err := l.Opened(c.Conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Millisecond) // distinct "since" times
+	newer := newTestConn("a@x", "10.0.0.2")
+	if err := l.Opened(newer.Conn); err != nil {
+		t.Fatalf("kick_oldest rejected the new source IP: %v", err)
+	}
+	if !old1.closed() || !old2.closed() {
+		t.Error("connections of the oldest source IP were not closed")
+	}
+	if newer.closed() {
+		t.Error("the new connection was closed")
+	}
+	// The kicked connections finish after the new IP took the slot; they must not free it.
+	l.Closed(old1.Conn, nil)
+	l.Closed(old2.Conn, nil)
+	if ips := l.users["a@x"]; len(ips) != 1 || ips["10.0.0.2"] == nil {
+		t.Errorf("source IPs after the kick: %v", ips)
+	}
+	// Coming back from the first IP kicks the second.
+	if err := l.Opened(newTestConn("a@x", "10.0.0.1").Conn); err != nil {
+		t.Fatal(err)
+	}
+	if !newer.closed() {
+		t.Error("10.0.0.2 was not kicked")
+	}
+}
+
+// TestIPLimitApplyWhileOpening runs reloads next to connections (go test -race).
+func TestIPLimitApplyWhileOpening(t *testing.T) {
+	var l IPLimiter
+	l.Apply(ipLimitConfig(ipPolicyKickOldest, 1, models.ServerUser{Email: "a@x"}))
+	var wg sync.WaitGroup
+	stop, started := done.New(), make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		close(started)
+		for i := 0; !stop.Done(); i++ {
+			l.Apply(ipLimitConfig(ipPolicyKickOldest, 1+i%2, models.ServerUser{Email: "a@x"}))
+		}
+	}()
+	<-started
+	var open []*testConn
+	for i := 0; i < 2000; i++ {
+		c := newTestConn("a@x", "10.0.0."+strconv.Itoa(i%4))
+		if err := l.Opened(c.Conn); err != nil {
+			t.Fatal(err)
+		}
+		if open = append(open, c); len(open) > 3 {
+			l.Closed(open[0].Conn, nil)
+			open = open[1:]
+		}
+	}
+	_ = stop.Close()
+	wg.Wait()
+}
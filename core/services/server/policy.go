@@ -0,0 +1,196 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : policy.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 00:58:36
+ * Description : Destination port and sniffed protocol policy, with blocked attempts counted per user.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/metrics"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// Blackhole outbounds that policy rules route to; the PolicyCounter refuses what reaches them.
+const (
+	outboundPolicyPort     = "policy-port"
+	outboundPolicyProtocol = "policy-protocol"
+)
+
+// policyProtocols are the protocols Xray's sniffer reports.
+var policyProtocols = map[string]bool{"bittorrent": true, "http": true, "tls": true, "quic": true}
+
+// portRange is an inclusive destination port range.
+type portRange struct {
+	from, to int
+}
+
+// parsePorts parses "443" and "8000-8999" entries, sorted and merged.
+func parsePorts(list []string) ([]portRange, error) {
+	var out []portRange
+	for _, p := range list {
+		from, to, isRange := strings.Cut(strings.TrimSpace(p), "-")
+		lo, err := strconv.Atoi(from)
+		hi := lo
+		if isRange && err == nil {
+			hi, err = strconv.Atoi(to)
+		}
+		if err != nil || lo < 1 || hi > 65535 || lo > hi {
+			return nil, fmt.Errorf("port %q: must be 1-65535 or a range like 8000-8999", p)
+		}
+		out = append(out, portRange{lo, hi})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].from < out[j].from })
+	merged := out[:0]
+	for _, r := range out {
+		if n := len(merged); n > 0 && r.from <= merged[n-1].to+1 {
+			if r.to > merged[n-1].to {
+				merged[n-1].to = r.to
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged, nil
+}
+
+// blockedPorts returns the ports outside allow_ports (when set) plus deny_ports, in Xray port list syntax.
+func blockedPorts(p *models.PolicyConfig) (string, error) {
+	allow, err := parsePorts(p.AllowPorts)
+	if err != nil {
+		return "", fmt.Errorf("policy.allow_ports: %v", err)
+	}
+	deny, err := parsePorts(p.DenyPorts)
+	if err != nil {
+		return "", fmt.Errorf("policy.deny_ports: %v", err)
+	}
+	blocked := deny
+	if len(allow) > 0 {
+		next := 1
+		for _, r := range allow {
+			if r.from > next {
+				blocked = append(blocked, portRange{next, r.from - 1})
+			}
+			next = r.to + 1
+		}
+		if next <= 65535 {
+			blocked = append(blocked, portRange{next, 65535})
+		}
+	}
+	parts := make([]string, 0, len(blocked))
+	for _, r := range blocked {
+		if r.from == r.to {
+			parts = append(parts, strconv.Itoa(r.from))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r.from, r.to))
+		}
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// buildPolicyRules returns the routing rules of the policy (evaluated before egress rules)
+// and the blackhole outbounds they need.
+func buildPolicyRules(cfg *models.ServerConfig) ([]xrayRule, []xrayOutbound, error) {
+	var rules []xrayRule
+	var outbounds []xrayOutbound
+	if len(cfg.Policy.BlockProtocols) > 0 {
+		protocols := make([]string, 0, len(cfg.Policy.BlockProtocols))
+		for _, p := range cfg.Policy.BlockProtocols {
+			p = strings.ToLower(p)
+			if !policyProtocols[p] {
+				return nil, nil, fmt.Errorf("policy.block_protocols: %q: must be bittorrent, http, tls or quic", p)
+			}
+			protocols = append(protocols, p)
+		}
+		rules = append(rules, xrayRule{Type: "field", Protocol: protocols, OutboundTag: outboundPolicyProtocol})
+		outbounds = append(outbounds, xrayOutbound{Protocol: "blackhole", Tag: outboundPolicyProtocol})
+	}
+	ports, err := blockedPorts(&cfg.Policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ports != "" {
+		rules = append(rules, xrayRule{Type: "field", Port: ports, OutboundTag: outboundPolicyPort})
+		outbounds = append(outbounds, xrayOutbound{Protocol: "blackhole", Tag: outboundPolicyPort})
+	}
+	return rules, outbounds, nil
+}
+
+// PolicyCounter is a tracker observer that refuses connections routed to the policy outbounds
+// and counts them per user and rule.
+type PolicyCounter struct {
+	blocked map[[2]string]float64 // [user, rule]
+	mu      sync.Mutex
+}
+
+// Opened implements conntrack.Observer.
+func (p *PolicyCounter) Opened(c *conntrack.Conn) error {
+	var rule string
+	switch c.Outbound {
+	case outboundPolicyPort:
+		rule = "port"
+	case outboundPolicyProtocol:
+		rule = "protocol"
+	default:
+		return nil
+	}
+	p.mu.Lock()
+	if p.blocked == nil {
+		p.blocked = make(map[[2]string]float64)
+	}
+	p.blocked[[2]string{c.Email, rule}]++
+	p.mu.Unlock()
+	logger.Debugf("policy: blocked %s to %s (%s %s)", c.Email, c.Target, rule, c.Protocol)
+	if rule == "protocol" {
+		return conntrack.Reject(rule, "%s: protocol %s is not allowed", c.Email, c.Protocol)
+	}
+	return conntrack.Reject(rule, "%s: destination port of %s is not allowed", c.Email, c.Target)
+}
+
+// Closed implements conntrack.Observer.
+func (p *PolicyCounter) Closed(c *conntrack.Conn, err error) {}
+
+// Collect implements metrics.Collector.
+func (p *PolicyCounter) Collect() []metrics.Family {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make([][2]string, 0, len(p.blocked))
+	for k := range p.blocked {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	const name = "abdal_server_policy_blocked_total"
+	f := metrics.Family{Name: name, Help: "Connections refused by the destination policy per user and rule.", Type: "counter"}
+	for _, k := range keys {
+		f.Samples = append(f.Samples, metrics.Sample{
+			Name:   name,
+			Labels: []metrics.Label{{Name: "user", Value: k[0]}, {Name: "rule", Value: k[1]}},
+			Value:  p.blocked[k],
+		})
+	}
+	return []metrics.Family{f}
+}
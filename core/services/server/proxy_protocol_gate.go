@@ -0,0 +1,326 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : proxy_protocol_gate.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 11:02:15
+ * Description : Front gate for the VLESS inbound: accepts PROXY protocol only from trusted load balancers,
+ *                owns the public socket for upgrade handover and relays to Xray's internal listener with the real client address.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/netutil"
+	"github.com/ebrasha/abdal-gost-proxy/core/security"
+	"github.com/pires/go-proxyproto"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	xnet "github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/routing"
+)
+
+// gatePlan describes a ProxyProtocolGate placed in front of Xray's internal listener.
+type gatePlan struct {
+	tag          string
+	network      string // "tcp" or "unix"
+	listenAddr   string
+	internalAddr string       // Xray's listener: a socket path in internalSockets; empty = dispatch as tag
+	acceptHeader bool         // peers may (or, without trusted, must) send a PROXY header
+	trusted      []*net.IPNet // when set, only these peers may send a PROXY header
+	unixSocket   models.UnixSocketConfig
+}
+
+// ProxyProtocolGate listens on the public inbound address and relays TCP to Xray's internal listener.
+// Trusted peers may send a PROXY v1/v2 header; untrusted peers sending one are dropped.
+// Every relayed connection starts with a fresh PROXY v2 header so Xray sees the real client.
+type ProxyProtocolGate struct {
+	plan     gatePlan
+	xray     *core.Instance // dispatches connections of plans without an internal listener
+	raw      net.Listener   // the public socket (handed to the new process on upgrade)
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	onReject func(reason string)
+	mu       sync.Mutex
+}
+
+// newProxyProtocolGate creates and starts the gate, reusing a listener inherited from the previous process when present.
+// onReject (optional) is called for every connection dropped because of its PROXY header.
+func newProxyProtocolGate(p gatePlan, xray *core.Instance, onReject func(reason string)) (*ProxyProtocolGate, error) {
+	ln, err := takeInheritedListener(listenerKey(p.network, p.listenAddr))
+	if err != nil {
+		return nil, err
+	}
+	if ln == nil && p.network == "unix" {
+		ln, err = netutil.ListenUnix(p.listenAddr, p.unixSocket.Mode, p.unixSocket.Owner)
+	} else if ln == nil {
+		ln, err = net.Listen("tcp", p.listenAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g := &ProxyProtocolGate{plan: p, xray: xray, raw: ln, conns: make(map[net.Conn]struct{}), onReject: onReject}
+	g.listener = &proxyproto.Listener{
+		Listener:          ln,
+		Policy:            g.policy,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go g.serve(g.listener)
+	return g, nil
+}
+
+// policy lets trusted sources use a PROXY header and rejects headers from everyone else;
+// without trusted sources the header is required (proxy_protocol on) or not parsed at all.
+func (g *ProxyProtocolGate) policy(upstream net.Addr) (proxyproto.Policy, error) {
+	switch {
+	case !g.plan.acceptHeader:
+		return proxyproto.SKIP, nil
+	case len(g.plan.trusted) == 0:
+		return proxyproto.REQUIRE, nil
+	case security.IsTrustedSource(g.plan.trusted, upstream):
+		return proxyproto.USE, nil
+	}
+	return proxyproto.REJECT, nil
+}
+
+func (g *ProxyProtocolGate) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go g.handle(conn)
+	}
+}
+
+func (g *ProxyProtocolGate) handle(in net.Conn) {
+	defer in.Close()
+	if !g.track(in) {
+		return
+	}
+	defer g.untrack(in)
+	// A zero-length read parses the PROXY header and surfaces policy violations.
+	if _, err := in.Read(nil); err != nil {
+		if err != io.EOF && g.onReject != nil {
+			g.onReject("proxy_protocol")
+		}
+		return
+	}
+	if g.plan.internalAddr == "" {
+		g.dispatch(in)
+		return
+	}
+	out, err := net.Dial(internalNetwork(g.plan.internalAddr), g.plan.internalAddr)
+	if err != nil {
+		return
+	}
+	defer out.Close()
+	if _, err := relayHeader(in).WriteTo(out); err != nil {
+		return
+	}
+	relayConns(in, out)
+}
+
+// dispatch hands in to Xray's router as a connection of the plan's inbound tag, as the dokodemo-door
+// listener of an ungated reverse tunnel would (same placeholder destination, see xrayDokodemo).
+func (g *ProxyProtocolGate) dispatch(in net.Conn) {
+	d, ok := g.xray.GetFeature(routing.DispatcherType()).(routing.Dispatcher)
+	if !ok {
+		return
+	}
+	_, port, _ := net.SplitHostPort(g.plan.listenAddr)
+	dest, err := xnet.ParseDestination("tcp:127.0.0.1:" + port)
+	if err != nil {
+		return
+	}
+	ctx := session.ContextWithInbound(context.Background(), &session.Inbound{
+		Source: xnet.DestinationFromAddr(in.RemoteAddr()),
+		Tag:    g.plan.tag,
+		Name:   "dokodemo-door",
+	})
+	link, err := d.Dispatch(ctx, dest)
+	if err != nil {
+		return
+	}
+	go func() {
+		if err := buf.Copy(buf.NewReader(in), link.Writer); err != nil {
+			_ = common.Interrupt(link.Writer)
+			return
+		}
+		_ = common.Close(link.Writer) // half-close: the answer may still be on its way
+	}()
+	_ = buf.Copy(link.Reader, buf.NewWriter(in))
+	_ = common.Interrupt(link.Reader)
+}
+
+// relayHeader describes the client for Xray; peers without an IP address (unix sockets) get a LOCAL header.
+func relayHeader(in net.Conn) *proxyproto.Header {
+	_, srcTCP := in.RemoteAddr().(*net.TCPAddr)
+	_, dstTCP := in.LocalAddr().(*net.TCPAddr)
+	if srcTCP && dstTCP {
+		return proxyproto.HeaderProxyFromAddrs(2, in.RemoteAddr(), in.LocalAddr())
+	}
+	return &proxyproto.Header{Version: 2, Command: proxyproto.LOCAL, TransportProtocol: proxyproto.UNSPEC}
+}
+
+// track registers an active connection; it fails once the gate has been force-closed.
+func (g *ProxyProtocolGate) track(c net.Conn) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.conns == nil {
+		return false
+	}
+	g.conns[c] = struct{}{}
+	return true
+}
+
+func (g *ProxyProtocolGate) untrack(c net.Conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.conns, c)
+}
+
+// Active returns the number of connections currently relayed.
+func (g *ProxyProtocolGate) Active() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.conns)
+}
+
+// CloseConns force-closes every relayed connection and returns how many were open.
+func (g *ProxyProtocolGate) CloseConns() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := len(g.conns)
+	for c := range g.conns {
+		_ = c.Close()
+	}
+	g.conns = nil
+	return n
+}
+
+// relayConns copies both directions and half-closes the peer when one side finishes.
+func relayConns(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = cw.CloseWrite()
+		} else {
+			_ = dst.Close()
+		}
+	}
+	go copyHalf(b, a)
+	go copyHalf(a, b)
+	wg.Wait()
+}
+
+// internalSockets is the private directory (mode 0700) of the sockets Xray listens on behind gates.
+// Unlike a loopback port freed for Xray to bind, a name in it cannot be taken, or connected to, by another user's process.
+var internalSockets struct {
+	dir  string
+	next int
+	mu   sync.Mutex
+}
+
+// internalListenAddr returns a new address for an internal listener of Xray: a socket path in internalSockets.
+// Windows has none (see checkGates).
+func internalListenAddr() (string, error) {
+	if runtime.GOOS == "windows" {
+		return "", fmt.Errorf("internal listeners need unix sockets, which Xray cannot listen on under Windows")
+	}
+	internalSockets.mu.Lock()
+	defer internalSockets.mu.Unlock()
+	if internalSockets.dir == "" {
+		dir, err := os.MkdirTemp("", "abdal-gost-")
+		if err != nil {
+			return "", fmt.Errorf("internal sockets: %v", err)
+		}
+		internalSockets.dir = dir
+	}
+	internalSockets.next++
+	return filepath.Join(internalSockets.dir, strconv.Itoa(internalSockets.next)+".sock"), nil
+}
+
+// isInternalSocket reports whether addr is a socket path handed out by internalListenAddr.
+func isInternalSocket(addr string) bool {
+	internalSockets.mu.Lock()
+	defer internalSockets.mu.Unlock()
+	return internalSockets.dir != "" && filepath.Dir(addr) == internalSockets.dir
+}
+
+// internalNetwork returns the network to dial an internal listener address on.
+func internalNetwork(addr string) string {
+	if isInternalSocket(addr) {
+		return "unix"
+	}
+	return "tcp"
+}
+
+// removeInternalSockets deletes the internal socket directory once Xray no longer listens in it.
+func removeInternalSockets() {
+	internalSockets.mu.Lock()
+	defer internalSockets.mu.Unlock()
+	if internalSockets.dir != "" {
+		_ = os.RemoveAll(internalSockets.dir)
+		internalSockets.dir = ""
+	}
+}
+
+// key identifies the gate's public socket for upgrade handover.
+func (g *ProxyProtocolGate) key() string {
+	return listenerKey(g.plan.network, g.plan.listenAddr)
+}
+
+// File duplicates the public socket for the new process.
+func (g *ProxyProtocolGate) File() (*os.File, error) {
+	return netutil.ListenerFile(g.raw)
+}
+
+// Detach stops accepting without removing the socket file, which the new process now serves.
+func (g *ProxyProtocolGate) Detach() error {
+	netutil.KeepUnixSocket(g.raw)
+	return g.Close()
+}
+
+// Run blocks until ctx is done; then closes the gate.
+func (g *ProxyProtocolGate) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return g.Close()
+}
+
+// Close stops the listener; connections already relayed keep running.
+func (g *ProxyProtocolGate) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.listener == nil {
+		return nil
+	}
+	err := g.listener.Close()
+	g.listener = nil
+	return err
+}
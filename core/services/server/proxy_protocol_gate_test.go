@@ -0,0 +1,259 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : proxy_protocol_gate_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 06:11:27
+ * Description : Tests for the PROXY protocol gate: trusted and untrusted peers and the internal socket directory.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/security"
+	"github.com/pires/go-proxyproto"
+)
+
+// internalStandIn stands in for Xray's internal listener: it reads the gate's PROXY header and answers
+// "<client address> <first line>" on every connection.
+func internalStandIn(t *testing.T, network, addr string) string {
+	t.Helper()
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				h, err := proxyproto.Read(r)
+				if err != nil {
+					return
+				}
+				line, _ := r.ReadString('\n')
+				_, _ = io.WriteString(c, h.SourceAddr.String()+" "+line)
+			}(c)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// startGate runs a gate on a free loopback port in front of internal; it counts rejections.
+func startGate(t *testing.T, p gatePlan, internal string) (addr string, rejected *atomic.Int32) {
+	t.Helper()
+	rejected = new(atomic.Int32)
+	p.network, p.listenAddr, p.internalAddr = "tcp", "127.0.0.1:0", internal
+	g, err := newProxyProtocolGate(p, nil, func(reason string) {
+		if reason == "proxy_protocol" {
+			rejected.Add(1)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+	return g.raw.Addr().String(), rejected
+}
+
+// ask connects to the gate, sends a PROXY header claiming src (none when empty) and one line; it returns the answer.
+func ask(t *testing.T, gate, src string) string {
+	t.Helper()
+	c, err := net.DialTimeout("tcp", gate, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if src != "" {
+		h := proxyproto.HeaderProxyFromAddrs(2, &net.TCPAddr{IP: net.ParseIP(src), Port: 51000}, c.RemoteAddr())
+		if _, err := h.WriteTo(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _ = io.WriteString(c, "ping\n")
+	answer, _ := io.ReadAll(c)
+	return strings.TrimSpace(string(answer))
+}
+
+func TestGateTrustedSources(t *testing.T) {
+	internal := internalStandIn(t, "tcp", "127.0.0.1:0")
+	trusted, err := security.ParseTrustedSources([]string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gate, rejected := startGate(t, gatePlan{acceptHeader: true, trusted: trusted}, internal)
+
+	// A trusted load balancer's header is passed on to Xray.
+	if got := ask(t, gate, "203.0.113.7"); got != "203.0.113.7:51000 ping" {
+		t.Errorf("trusted peer with a header: %q", got)
+	}
+	// A trusted peer may also connect without one.
+	if got := ask(t, gate, ""); !strings.HasPrefix(got, "127.0.0.1:") || !strings.HasSuffix(got, " ping") {
+		t.Errorf("trusted peer without a header: %q", got)
+	}
+	if n := rejected.Load(); n != 0 {
+		t.Errorf("%d rejections", n)
+	}
+}
+
+func TestGateUntrustedSources(t *testing.T) {
+	internal := internalStandIn(t, "tcp", "127.0.0.1:0")
+	trusted, err := security.ParseTrustedSources([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gate, rejected := startGate(t, gatePlan{acceptHeader: true, trusted: trusted}, internal)
+
+	// An untrusted peer cannot spoof its address.
+	if got := ask(t, gate, "203.0.113.7"); got != "" {
+		t.Errorf("untrusted peer with a header reached Xray: %q", got)
+	}
+	waitFor(t, 5*time.Second, func() bool { return rejected.Load() == 1 })
+	// Without a header it is relayed under its own address.
+	if got := ask(t, gate, ""); !strings.HasPrefix(got, "127.0.0.1:") || !strings.HasSuffix(got, " ping") {
+		t.Errorf("untrusted peer without a header: %q", got)
+	}
+}
+
+func TestGateHeaderPolicies(t *testing.T) {
+	internal := internalStandIn(t, "tcp", "127.0.0.1:0")
+
+	// proxy_protocol on without trusted sources: every peer must send a header.
+	required, rejected := startGate(t, gatePlan{acceptHeader: true}, internal)
+	if got := ask(t, required, "198.51.100.4"); got != "198.51.100.4:51000 ping" {
+		t.Errorf("required header: %q", got)
+	}
+	if got := ask(t, required, ""); got != "" {
+		t.Errorf("missing header reached Xray: %q", got)
+	}
+	waitFor(t, 5*time.Second, func() bool { return rejected.Load() == 1 })
+
+	// proxy_protocol off (gate for upgrade/drain only): a header is not parsed, so it cannot spoof the address.
+	plain, _ := startGate(t, gatePlan{}, internal)
+	if got := ask(t, plain, ""); !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Errorf("plain connection: %q", got)
+	}
+	if got := ask(t, plain, "198.51.100.4"); strings.HasPrefix(got, "198.51.100.4") {
+		t.Errorf("header honoured with proxy_protocol off: %q", got)
+	}
+}
+
+func TestInternalSockets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no internal listeners on Windows")
+	}
+	t.Cleanup(removeInternalSockets)
+	a, err := internalListenAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := internalListenAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || filepath.Dir(a) != filepath.Dir(b) || !isInternalSocket(a) || !security.IsUnixSocketPath(a) {
+		t.Fatalf("internal addresses %q, %q", a, b)
+	}
+	if isInternalSocket("/tmp/other.sock") || isInternalSocket("127.0.0.1:443") {
+		t.Error("foreign address taken for an internal socket")
+	}
+	fi, err := os.Stat(filepath.Dir(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o700 {
+		t.Errorf("directory mode %o, want 700", perm)
+	}
+
+	// The gate relays to a listener on an internal socket.
+	gate, _ := startGate(t, gatePlan{}, internalStandIn(t, "unix", a))
+	if got := ask(t, gate, ""); !strings.HasPrefix(got, "127.0.0.1:") || !strings.HasSuffix(got, " ping") {
+		t.Errorf("through the internal socket: %q", got)
+	}
+
+	removeInternalSockets()
+	if _, err := os.Stat(filepath.Dir(a)); !os.IsNotExist(err) {
+		t.Errorf("directory left behind: %v", err)
+	}
+	if isInternalSocket(a) {
+		t.Error("removed directory still counts as internal")
+	}
+}
+
+func TestCheckGates(t *testing.T) {
+	cfg := testServerConfig(t)
+	cfg.Inbounds = []models.InboundConfig{{Tag: "lb", ListenPort: 8443, ProxyProtocol: &models.ProxyProtocolConfig{Enabled: true, TrustedSources: []string{"10.0.0.0/8"}}}}
+	drained := *cfg
+	drained.Inbounds = nil
+	drained.Drain.TimeoutSeconds = 5
+	for name, c := range map[string]*models.ServerConfig{"trusted sources": cfg, "drain": &drained} {
+		err := checkGates(c)
+		if runtime.GOOS == "windows" && err == nil {
+			t.Errorf("%s accepted on Windows", name)
+		}
+		if runtime.GOOS != "windows" && err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestPlanGatesDrain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no internal listeners on Windows")
+	}
+	t.Cleanup(removeInternalSockets)
+	cfg := testServerConfig(t)
+	cfg.Drain.TimeoutSeconds = 5
+	cfg.Reverse = []models.ReverseTunnelConfig{{Name: "web", Listen: "127.0.0.1:18300", User: "admin@abdal"}}
+	planned, plans, err := planGates(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 2 || plans[0].listenAddr != "127.0.0.1:24443" || !isInternalSocket(plans[0].internalAddr) {
+		t.Fatalf("plans = %+v", plans)
+	}
+	if plans[1].listenAddr != "127.0.0.1:18300" || plans[1].internalAddr != "" {
+		t.Errorf("reverse tunnel plan %+v, want a gate that dispatches into Xray", plans[1])
+	}
+	if planned.ListenAddress != plans[0].internalAddr || planned.ListenPort != 0 || !planned.ProxyProtocol.Enabled {
+		t.Errorf("inbound moved to %s:%d", planned.ListenAddress, planned.ListenPort)
+	}
+	x, err := buildXrayConfig(planned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range x.Inbounds {
+		if in.Tag == "reverse-web" {
+			t.Errorf("Xray listens for the gated reverse tunnel on %s", in.Listen)
+		}
+	}
+	if cfg.ListenAddress != "127.0.0.1" || cfg.ListenPort != 24443 {
+		t.Error("planGates modified the caller's config")
+	}
+}
@@ -0,0 +1,315 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : quota.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 23:21:46
+ * Description : Per-user traffic quotas and expiry, with counters persisted to the user store.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/userstore"
+	"github.com/ebrasha/abdal-gost-proxy/core/webhook"
+)
+
+// quotaCheckInterval is how often open connections are counted against quotas and expiry.
+const quotaCheckInterval = 10 * time.Second
+
+// QuotaTracker is a tracker observer that counts traffic per user, refuses users that are over quota or
+// expired, closes their sessions when that happens, and saves the counters of stored users.
+type QuotaTracker struct {
+	users     map[string]*userUsage // by email
+	counted   map[*conntrack.Conn]userstore.Traffic
+	storePath string
+	saveEvery time.Duration
+	lastSave  time.Time
+	tracker   *conntrack.Tracker
+	hooks     *webhook.Dispatcher // optional
+	mu        sync.Mutex
+	saveMu    sync.Mutex
+}
+
+// userUsage is the traffic and limits of one user.
+type userUsage struct {
+	quota    int64     // bytes; 0 = unlimited
+	expires  time.Time // zero = never
+	used     userstore.Traffic
+	pending  userstore.Traffic // counted but not yet saved to the store
+	stored   bool              // kept in the user store
+	disabled string            // "quota", "expired" or ""
+}
+
+// checkQuotas validates quota_gb and expires_at of the configured users.
+func checkQuotas(cfg *models.ServerConfig) error {
+	for i := range cfg.Users {
+		u := &cfg.Users[i]
+		if u.QuotaGB < 0 {
+			return fmt.Errorf("user %s: quota_gb must not be negative", u.Email)
+		}
+		if _, err := userstore.ParseExpiry(u.ExpiresAt); err != nil {
+			return fmt.Errorf("user %s: %v", u.Email, err)
+		}
+	}
+	if cfg.UserStore.SaveIntervalSeconds < 0 {
+		return fmt.Errorf("user_store.save_interval_seconds must not be negative")
+	}
+	return nil
+}
+
+// metered reports whether traffic is counted per user (quotas, or usage saved to the user store).
+func metered(cfg *models.ServerConfig) bool {
+	if cfg.UserStore.Path != "" {
+		return true
+	}
+	for _, u := range cfg.Users {
+		if u.QuotaGB > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// quotasChanged reports whether quota_gb or expires_at of any user kept in cfg differs from old.
+func quotasChanged(old, cfg *models.ServerConfig) bool {
+	type limits struct {
+		quota   float64
+		expires string
+	}
+	before := make(map[string]limits, len(old.Users))
+	for _, u := range old.Users {
+		before[u.Email] = limits{u.QuotaGB, u.ExpiresAt}
+	}
+	for _, u := range cfg.Users {
+		if l, ok := before[u.Email]; ok && l != (limits{u.QuotaGB, u.ExpiresAt}) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply sets limits from cfg and reloads the saved counters of stored users; counters of other users are kept in memory.
+func (q *QuotaTracker) Apply(cfg *models.ServerConfig, tracker *conntrack.Tracker) error {
+	// Holding saveMu keeps a save from moving pending counters into the store between the read and the swap.
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+	saved := make(map[string]userstore.Traffic)
+	if cfg.UserStore.Path != "" {
+		err := userstore.With(cfg.UserStore.Path, func(st *userstore.Store) error {
+			users, err := st.List()
+			for _, u := range users {
+				saved[u.Email] = userstore.Traffic{Up: u.UpBytes, Down: u.DownBytes}
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	q.mu.Lock()
+	if q.counted == nil {
+		q.counted = make(map[*conntrack.Conn]userstore.Traffic)
+	}
+	users := make(map[string]*userUsage, len(cfg.Users))
+	for _, u := range cfg.Users {
+		uu := &userUsage{quota: int64(u.QuotaGB * 1e9)}
+		uu.expires, _ = userstore.ParseExpiry(u.ExpiresAt)
+		if old := q.users[u.Email]; old != nil {
+			uu.used, uu.pending, uu.disabled = old.used, old.pending, old.disabled
+		}
+		if t, ok := saved[u.Email]; ok {
+			uu.stored = true
+			uu.used = userstore.Traffic{Up: t.Up + uu.pending.Up, Down: t.Down + uu.pending.Down}
+		} else {
+			uu.pending = userstore.Traffic{}
+		}
+		users[u.Email] = uu
+	}
+	q.users, q.tracker = users, tracker
+	q.storePath = cfg.UserStore.Path
+	q.saveEvery = time.Duration(cfg.UserStore.SaveIntervalSeconds) * time.Second
+	if q.saveEvery == 0 {
+		q.saveEvery = time.Minute
+	}
+	q.mu.Unlock()
+	q.check(time.Now())
+	return nil
+}
+
+// run counts open connections, enforces limits and saves counters until ctx is cancelled.
+func (q *QuotaTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(quotaCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			q.check(now)
+			q.mu.Lock()
+			due := now.Sub(q.lastSave) >= q.saveEvery
+			q.mu.Unlock()
+			if due {
+				q.save()
+			}
+		}
+	}
+}
+
+// account adds the bytes c transferred since it was last counted; q.mu must be held.
+func (q *QuotaTracker) account(c *conntrack.Conn) {
+	uu := q.users[c.Email]
+	prev := q.counted[c]
+	cur := userstore.Traffic{Up: c.Uplink(), Down: c.Downlink()}
+	q.counted[c] = cur
+	if uu == nil {
+		return
+	}
+	d := userstore.Traffic{Up: cur.Up - prev.Up, Down: cur.Down - prev.Down}
+	uu.used.Up += d.Up
+	uu.used.Down += d.Down
+	if uu.stored {
+		uu.pending.Up += d.Up
+		uu.pending.Down += d.Down
+	}
+}
+
+// limitOf returns why uu may not connect at now ("quota", "expired" or "").
+func limitOf(uu *userUsage, now time.Time) string {
+	switch {
+	case !uu.expires.IsZero() && !now.Before(uu.expires):
+		return "expired"
+	case uu.quota > 0 && uu.used.Up+uu.used.Down >= uu.quota:
+		return "quota"
+	}
+	return ""
+}
+
+// check counts the open connections and disables users that reached their quota or expired.
+func (q *QuotaTracker) check(now time.Time) {
+	q.mu.Lock()
+	tracker := q.tracker
+	if tracker != nil {
+		for _, c := range tracker.Conns() {
+			if _, ok := q.counted[c]; ok {
+				q.account(c)
+			}
+		}
+	}
+	var disabled []string
+	for email, uu := range q.users {
+		reason := limitOf(uu, now)
+		if reason != "" && uu.disabled != reason {
+			q.disabledEvent(email, uu, reason)
+		} else if reason == "" && uu.disabled != "" {
+			logger.Infof("quota: %s enabled again", email)
+		}
+		uu.disabled = reason
+		if reason != "" {
+			disabled = append(disabled, email)
+		}
+	}
+	q.mu.Unlock()
+	if tracker == nil {
+		return
+	}
+	for _, email := range disabled {
+		if n := tracker.CloseWhere(func(c *conntrack.Conn) bool { return c.Email == email }); n > 0 {
+			logger.Infof("quota: closed %d connections of %s", n, email)
+		}
+	}
+}
+
+// disabledEvent logs and notifies that email was disabled; q.mu must be held.
+func (q *QuotaTracker) disabledEvent(email string, uu *userUsage, reason string) {
+	data := map[string]interface{}{"user": email, "up_bytes": uu.used.Up, "down_bytes": uu.used.Down}
+	event := eventQuotaReached
+	if reason == "expired" {
+		event = eventUserExpired
+		data["expires_at"] = uu.expires.Format(time.RFC3339)
+		logger.Warnf("quota: %s expired at %s", email, uu.expires.Format(time.RFC3339))
+	} else {
+		data["quota_bytes"] = uu.quota
+		logger.Warnf("quota: %s reached its quota (%d of %d bytes)", email, uu.used.Up+uu.used.Down, uu.quota)
+	}
+	if q.hooks != nil {
+		q.hooks.Notify(event, data)
+	}
+}
+
+// save writes the pending counters of stored users; on failure they stay pending for the next save.
+func (q *QuotaTracker) save() {
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+	q.mu.Lock()
+	path := q.storePath
+	deltas := make(map[string]userstore.Traffic)
+	for email, uu := range q.users {
+		if uu.pending != (userstore.Traffic{}) {
+			deltas[email] = uu.pending
+		}
+	}
+	q.lastSave = time.Now()
+	q.mu.Unlock()
+	if path == "" || len(deltas) == 0 {
+		return
+	}
+	if err := userstore.With(path, func(st *userstore.Store) error { return st.AddTraffic(deltas) }); err != nil {
+		logger.Errorf("user store: saving traffic counters: %v", err)
+		return
+	}
+	q.mu.Lock()
+	for email, d := range deltas {
+		if uu := q.users[email]; uu != nil {
+			uu.pending.Up -= d.Up
+			uu.pending.Down -= d.Down
+		}
+	}
+	q.mu.Unlock()
+}
+
+// Opened implements conntrack.Observer.
+func (q *QuotaTracker) Opened(c *conntrack.Conn) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	uu := q.users[c.Email]
+	if uu == nil {
+		return nil
+	}
+	if reason := limitOf(uu, time.Now()); reason != "" {
+		if reason == "expired" {
+			return conntrack.Reject("expired", "%s: account expired", c.Email)
+		}
+		return conntrack.Reject("quota", "%s: traffic quota reached", c.Email)
+	}
+	q.counted[c] = userstore.Traffic{}
+	return nil
+}
+
+// Closed implements conntrack.Observer.
+func (q *QuotaTracker) Closed(c *conntrack.Conn, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.counted[c]; !ok {
+		return
+	}
+	q.account(c)
+	delete(q.counted, c)
+}
@@ -0,0 +1,625 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : reload.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 12:58:33
+ * Description : Hot reload: diffs a new server config against the running one and applies users, routing
+ *                and outbounds in place; only inbounds whose listener settings changed are rebuilt.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/routing"
+	xrayserial "github.com/xtls/xray-core/infra/conf/serial"
+	"github.com/xtls/xray-core/proxy"
+	vlessin "github.com/xtls/xray-core/proxy/vless/inbound"
+)
+
+// ReloadReport summarizes what a hot reload changed.
+type ReloadReport struct {
+	InboundsAdded    []string
+	InboundsRemoved  []string
+	InboundsRebuilt  []string
+	UsersAdded       int
+	UsersRemoved     int
+	OutboundsChanged []string
+	RoutingUpdated   bool
+	ReverseAdded     []string // reverse tunnels (portals) started
+	ReverseRemoved   []string
+	Settings         []string // server-side sections re-applied (log, metrics, access_log, decoy)
+}
+
+// String returns a one-line summary for logs.
+func (rep *ReloadReport) String() string {
+	var parts []string
+	add := func(label string, tags []string) {
+		if len(tags) > 0 {
+			parts = append(parts, label+" "+strings.Join(tags, ","))
+		}
+	}
+	add("inbounds added:", rep.InboundsAdded)
+	add("inbounds removed:", rep.InboundsRemoved)
+	add("inbounds rebuilt:", rep.InboundsRebuilt)
+	if rep.UsersAdded > 0 || rep.UsersRemoved > 0 {
+		parts = append(parts, fmt.Sprintf("users +%d/-%d", rep.UsersAdded, rep.UsersRemoved))
+	}
+	add("outbounds replaced:", rep.OutboundsChanged)
+	if rep.RoutingUpdated {
+		parts = append(parts, "routing updated")
+	}
+	add("reverse tunnels added:", rep.ReverseAdded)
+	add("reverse tunnels removed:", rep.ReverseRemoved)
+	add("settings updated:", rep.Settings)
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Reload validates newCfg and applies it to the running instance. If a step fails (for example a port that is
+// already in use), the steps already applied are rolled back and the running config is kept.
+func (r *XrayRunner) Reload(newCfg *models.ServerConfig) (rep *ReloadReport, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.instance == nil {
+		return nil, fmt.Errorf("reload: server is not running")
+	}
+	oldX, err := buildXrayConfig(r.config)
+	if err != nil {
+		return nil, err
+	}
+	newX, err := buildXrayConfig(newCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	planned, plans, err := planGates(newCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	jsonBytes, err := BuildXrayJSON(planned)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	coreCfg, err := xrayserial.LoadJSONConfig(bytes.NewReader(jsonBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	oldKeys, err := inboundKeys(r.config, oldX)
+	if err != nil {
+		return nil, err
+	}
+	newKeys, err := inboundKeys(newCfg, newX)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+
+	rep = &ReloadReport{}
+	prev := &previousConfig{cfg: r.config}
+	var undo []func() error
+	defer func() {
+		if err == nil {
+			return
+		}
+		rep = nil
+		var rerr error
+		for i := len(undo) - 1; i >= 0; i-- {
+			if e := undo[i](); e != nil && rerr == nil {
+				rerr = e
+			}
+		}
+		if rerr != nil {
+			err = fmt.Errorf("%v (rolling back: %v)", err, rerr)
+		}
+	}()
+
+	// New portals first, so the routing rules of new reverse tunnels never point at a missing outbound.
+	started, err := r.startPortals(newCfg)
+	undo = append(undo, func() error {
+		for _, name := range started {
+			_ = r.portals[name].Close()
+			delete(r.portals, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return rep, err
+	}
+	rep.ReverseAdded = started
+	if err := r.reloadOutbounds(oldX, newX, coreCfg, prev, rep, &undo); err != nil {
+		return rep, err
+	}
+	closed := r.closeReverseGates(newCfg) // before an ungated listener takes over the port
+	undo = append(undo, func() error {
+		if len(closed) == 0 {
+			return nil
+		}
+		if err := prev.load(); err != nil {
+			return err
+		}
+		return r.startGates(prev.plans, closed)
+	})
+	if err := r.reloadInbounds(oldX, newX, oldKeys, newKeys, coreCfg, plans, newCfg, prev, rep, &undo); err != nil {
+		return rep, err
+	}
+	if err := r.reloadRouting(oldX, newX, coreCfg, rep); err != nil {
+		return rep, err
+	}
+	if rep.RoutingUpdated {
+		undo = append(undo, func() error {
+			if err := prev.load(); err != nil {
+				return err
+			}
+			return r.setRouting(prev.core)
+		})
+	}
+	gated := r.reverseGatesToStart(newCfg)
+	undo = append(undo, func() error {
+		for tag := range gated {
+			r.closeGates(tag)
+		}
+		return nil
+	})
+	if err := r.startGates(plans, gated); err != nil {
+		return rep, err
+	}
+	rep.ReverseRemoved = r.closePortals(newCfg)
+	r.config = newCfg
+	return rep, nil
+}
+
+// previousConfig builds the Xray config of the running server config on demand, to roll back a failed reload.
+type previousConfig struct {
+	cfg   *models.ServerConfig
+	core  *core.Config
+	plans []gatePlan
+}
+
+func (p *previousConfig) load() error {
+	if p.core != nil {
+		return nil
+	}
+	planned, plans, err := planGates(p.cfg)
+	if err != nil {
+		return err
+	}
+	jsonBytes, err := BuildXrayJSON(planned)
+	if err != nil {
+		return err
+	}
+	coreCfg, err := xrayserial.LoadJSONConfig(bytes.NewReader(jsonBytes))
+	if err != nil {
+		return err
+	}
+	p.core, p.plans = coreCfg, plans
+	return nil
+}
+
+// inboundKeys returns, per tag, everything that requires rebuilding the listener when it changes
+// (the inbound JSON without its clients, plus gate settings that live outside Xray).
+func inboundKeys(cfg *models.ServerConfig, x *xrayConfig) (map[string]string, error) {
+	resolved, err := resolveInbounds(cfg)
+	if err != nil {
+		return nil, err
+	}
+	trusted := make(map[string]string, len(resolved))
+	for i := range resolved {
+		trusted[resolved[i].tag] = strings.Join(resolved[i].cfg.ProxyProtocol.TrustedSources, ",") + "|" + resolved[i].cfg.UnixSocket.Owner +
+			"|" + strconv.FormatBool(gateAll(cfg))
+	}
+	keys := make(map[string]string, len(x.Inbounds))
+	for _, in := range x.Inbounds {
+		if vs := in.vless(); vs != nil {
+			settings := *vs
+			settings.Clients = nil
+			in.Settings = &settings
+		}
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		keys[in.Tag] = string(b) + "|" + trusted[in.Tag]
+	}
+	return keys, nil
+}
+
+func (r *XrayRunner) reloadInbounds(oldX, newX *xrayConfig, oldKeys, newKeys map[string]string, coreCfg *core.Config, plans []gatePlan,
+	newCfg *models.ServerConfig, prev *previousConfig, rep *ReloadReport, undo *[]func() error) error {
+	im := r.instance.GetFeature(inbound.ManagerType()).(inbound.Manager)
+	ctx := context.Background()
+	oldByTag := make(map[string]xrayInbound, len(oldX.Inbounds))
+	for _, in := range oldX.Inbounds {
+		oldByTag[in.Tag] = in
+	}
+	newTags := make(map[string]bool, len(newX.Inbounds))
+	for _, in := range newX.Inbounds {
+		newTags[in.Tag] = true
+	}
+	for _, in := range oldX.Inbounds {
+		if newTags[in.Tag] {
+			continue
+		}
+		*undo = append(*undo, r.undoInbound(im, prev, in.Tag, true))
+		r.closeGates(in.Tag)
+		if err := im.RemoveHandler(ctx, in.Tag); err != nil {
+			return fmt.Errorf("remove inbound %s: %v", in.Tag, err)
+		}
+		rep.InboundsRemoved = append(rep.InboundsRemoved, in.Tag)
+	}
+	started := make(map[string]bool)
+	for _, in := range newX.Inbounds {
+		old, existed := oldByTag[in.Tag]
+		if existed && oldKeys[in.Tag] == newKeys[in.Tag] {
+			if in.vless() == nil {
+				continue // unchanged reverse tunnel listener
+			}
+			if usersReloadable(old, in) {
+				added, removed, err := r.reloadUsers(im, old, in, coreCfg, prev, undo)
+				if err != nil {
+					return fmt.Errorf("inbound %s users: %v", in.Tag, err)
+				}
+				rep.UsersAdded += added
+				rep.UsersRemoved += removed
+				continue
+			}
+		}
+		hc := findInboundConfig(coreCfg, in.Tag)
+		if hc == nil {
+			return fmt.Errorf("inbound %s missing from built config", in.Tag)
+		}
+		*undo = append(*undo, r.undoInbound(im, prev, in.Tag, existed))
+		if existed {
+			r.closeGates(in.Tag)
+			if err := im.RemoveHandler(ctx, in.Tag); err != nil {
+				return fmt.Errorf("remove inbound %s: %v", in.Tag, err)
+			}
+			rep.InboundsRebuilt = append(rep.InboundsRebuilt, in.Tag)
+		} else {
+			rep.InboundsAdded = append(rep.InboundsAdded, in.Tag)
+		}
+		if err := core.AddInboundHandler(r.instance, hc); err != nil {
+			return fmt.Errorf("add inbound %s: %v", in.Tag, err)
+		}
+		started[in.Tag] = true
+	}
+	if len(started) == 0 {
+		return nil
+	}
+	if err := r.chownSockets(newCfg, started); err != nil {
+		return err
+	}
+	return r.startGates(plans, started)
+}
+
+// undoInbound returns the rollback of a reload step that replaced, added or removed the inbound tag:
+// it stops whatever now runs under tag (a handler whose start failed stays registered) and, if the
+// inbound existed, starts it again from the previous config.
+func (r *XrayRunner) undoInbound(im inbound.Manager, prev *previousConfig, tag string, existed bool) func() error {
+	return func() error {
+		r.closeGates(tag)
+		_ = im.RemoveHandler(context.Background(), tag)
+		if !existed {
+			return nil
+		}
+		if err := prev.load(); err != nil {
+			return err
+		}
+		hc := findInboundConfig(prev.core, tag)
+		if hc == nil {
+			return fmt.Errorf("inbound %s missing from the previous config", tag)
+		}
+		if err := core.AddInboundHandler(r.instance, hc); err != nil {
+			_ = im.RemoveHandler(context.Background(), tag)
+			return fmt.Errorf("restore inbound %s: %v", tag, err)
+		}
+		tags := map[string]bool{tag: true}
+		if err := r.chownSockets(prev.cfg, tags); err != nil {
+			return err
+		}
+		return r.startGates(prev.plans, tags)
+	}
+}
+
+// usersReloadable reports whether users can be diffed in place (VLESS removes users by email, so emails must be unique and set).
+func usersReloadable(old, cur xrayInbound) bool {
+	for _, clients := range [][]xrayClient{old.vless().Clients, cur.vless().Clients} {
+		seen := make(map[string]bool, len(clients))
+		for _, c := range clients {
+			if c.Email == "" || seen[c.Email] {
+				return false
+			}
+			seen[c.Email] = true
+		}
+	}
+	return true
+}
+
+// reloadUsers removes and adds users on a running VLESS inbound, recording how to undo each change.
+func (r *XrayRunner) reloadUsers(im inbound.Manager, old, cur xrayInbound, coreCfg *core.Config, prev *previousConfig, undo *[]func() error) (added, removed int, err error) {
+	oldByEmail := make(map[string]xrayClient, len(old.vless().Clients))
+	for _, c := range old.vless().Clients {
+		oldByEmail[c.Email] = c
+	}
+	curByEmail := make(map[string]xrayClient, len(cur.vless().Clients))
+	for _, c := range cur.vless().Clients {
+		curByEmail[c.Email] = c
+	}
+	var toRemove, toAdd []string
+	for email, c := range oldByEmail {
+		if n, ok := curByEmail[email]; !ok || n != c {
+			toRemove = append(toRemove, email)
+		}
+	}
+	for email, c := range curByEmail {
+		if o, ok := oldByEmail[email]; !ok || o != c {
+			toAdd = append(toAdd, email)
+		}
+	}
+	if len(toRemove) == 0 && len(toAdd) == 0 {
+		return 0, 0, nil
+	}
+	um, err := userManager(im, cur.Tag)
+	if err != nil {
+		return 0, 0, err
+	}
+	ctx := context.Background()
+	for _, email := range toRemove {
+		if err := um.RemoveUser(ctx, email); err != nil {
+			return added, removed, err
+		}
+		email, tag := email, cur.Tag
+		*undo = append(*undo, func() error {
+			if err := prev.load(); err != nil {
+				return err
+			}
+			users, err := inboundUsers(prev.core, tag)
+			if err != nil {
+				return err
+			}
+			mu, err := users[email].ToMemoryUser()
+			if err != nil {
+				return err
+			}
+			return um.AddUser(ctx, mu)
+		})
+		removed++
+	}
+	users, err := inboundUsers(coreCfg, cur.Tag)
+	if err != nil {
+		return added, removed, err
+	}
+	for _, email := range toAdd {
+		u, ok := users[email]
+		if !ok {
+			return added, removed, fmt.Errorf("user %s missing from built config", email)
+		}
+		mu, err := u.ToMemoryUser()
+		if err != nil {
+			return added, removed, err
+		}
+		if err := um.AddUser(ctx, mu); err != nil {
+			return added, removed, err
+		}
+		email := email
+		*undo = append(*undo, func() error { return um.RemoveUser(ctx, email) })
+		added++
+	}
+	return added, removed, nil
+}
+
+// userManager returns the proxy.UserManager of a running inbound.
+func userManager(im inbound.Manager, tag string) (proxy.UserManager, error) {
+	h, err := im.GetHandler(context.Background(), tag)
+	if err != nil {
+		return nil, err
+	}
+	gi, ok := h.(proxy.GetInbound)
+	if !ok {
+		return nil, fmt.Errorf("inbound %s does not expose its proxy", tag)
+	}
+	um, ok := gi.GetInbound().(proxy.UserManager)
+	if !ok {
+		return nil, fmt.Errorf("inbound %s does not support user management", tag)
+	}
+	return um, nil
+}
+
+// findInboundConfig returns the built inbound handler config with the given tag.
+func findInboundConfig(coreCfg *core.Config, tag string) *core.InboundHandlerConfig {
+	for _, hc := range coreCfg.Inbound {
+		if hc.Tag == tag {
+			return hc
+		}
+	}
+	return nil
+}
+
+// inboundUsers returns the built VLESS users of an inbound by email.
+func inboundUsers(coreCfg *core.Config, tag string) (map[string]*protocol.User, error) {
+	hc := findInboundConfig(coreCfg, tag)
+	if hc == nil {
+		return nil, fmt.Errorf("inbound %s missing from built config", tag)
+	}
+	inst, err := hc.ProxySettings.GetInstance()
+	if err != nil {
+		return nil, err
+	}
+	vc, ok := inst.(*vlessin.Config)
+	if !ok {
+		return nil, fmt.Errorf("inbound %s is not VLESS", tag)
+	}
+	out := make(map[string]*protocol.User, len(vc.Clients))
+	for _, u := range vc.Clients {
+		out[u.Email] = u
+	}
+	return out, nil
+}
+
+// reloadOutbounds replaces outbounds whose settings changed and removes dropped ones.
+func (r *XrayRunner) reloadOutbounds(oldX, newX *xrayConfig, coreCfg *core.Config, prev *previousConfig, rep *ReloadReport, undo *[]func() error) error {
+	om := r.instance.GetFeature(outbound.ManagerType()).(outbound.Manager)
+	ctx := context.Background()
+	oldByTag := make(map[string]string, len(oldX.Outbounds))
+	for _, o := range oldX.Outbounds {
+		b, _ := json.Marshal(o)
+		oldByTag[o.Tag] = string(b)
+	}
+	newTags := make(map[string]bool, len(newX.Outbounds))
+	for _, o := range newX.Outbounds {
+		newTags[o.Tag] = true
+		b, _ := json.Marshal(o)
+		if prev, ok := oldByTag[o.Tag]; ok && prev == string(b) {
+			continue
+		}
+		var hc *core.OutboundHandlerConfig
+		for _, c := range coreCfg.Outbound {
+			if c.Tag == o.Tag {
+				hc = c
+			}
+		}
+		if hc == nil {
+			return fmt.Errorf("outbound %s missing from built config", o.Tag)
+		}
+		*undo = append(*undo, r.undoOutbound(om, prev, o.Tag, om.GetHandler(o.Tag) != nil))
+		if h := om.GetHandler(o.Tag); h != nil {
+			_ = om.RemoveHandler(ctx, o.Tag)
+			_ = h.Close()
+		}
+		if err := core.AddOutboundHandler(r.instance, hc); err != nil {
+			return fmt.Errorf("add outbound %s: %v", o.Tag, err)
+		}
+		if err := r.tracker.Wrap(r.instance, o.Tag); err != nil {
+			return fmt.Errorf("track outbound %s: %v", o.Tag, err)
+		}
+		rep.OutboundsChanged = append(rep.OutboundsChanged, o.Tag)
+	}
+	for tag := range oldByTag {
+		if newTags[tag] {
+			continue
+		}
+		*undo = append(*undo, r.undoOutbound(om, prev, tag, true))
+		if h := om.GetHandler(tag); h != nil {
+			_ = om.RemoveHandler(ctx, tag)
+			_ = h.Close()
+		}
+		rep.OutboundsChanged = append(rep.OutboundsChanged, tag)
+	}
+	return nil
+}
+
+// undoOutbound returns the rollback of a reload step that replaced, added or removed the outbound tag.
+func (r *XrayRunner) undoOutbound(om outbound.Manager, prev *previousConfig, tag string, existed bool) func() error {
+	return func() error {
+		if h := om.GetHandler(tag); h != nil {
+			_ = om.RemoveHandler(context.Background(), tag)
+			_ = h.Close()
+		}
+		if !existed {
+			return nil
+		}
+		if err := prev.load(); err != nil {
+			return err
+		}
+		for _, hc := range prev.core.Outbound {
+			if hc.Tag != tag {
+				continue
+			}
+			if err := core.AddOutboundHandler(r.instance, hc); err != nil {
+				return fmt.Errorf("restore outbound %s: %v", tag, err)
+			}
+			return r.tracker.Wrap(r.instance, tag)
+		}
+		return fmt.Errorf("outbound %s missing from the previous config", tag)
+	}
+}
+
+// reloadRouting replaces all routing rules when they changed.
+func (r *XrayRunner) reloadRouting(oldX, newX *xrayConfig, coreCfg *core.Config, rep *ReloadReport) error {
+	oldB, _ := json.Marshal(oldX.Routing)
+	newB, _ := json.Marshal(newX.Routing)
+	if bytes.Equal(oldB, newB) {
+		return nil
+	}
+	if err := r.setRouting(coreCfg); err != nil {
+		return err
+	}
+	rep.RoutingUpdated = true
+	return nil
+}
+
+// setRouting replaces all routing rules with those of coreCfg.
+func (r *XrayRunner) setRouting(coreCfg *core.Config) error {
+	var rc *router.Config
+	for _, app := range coreCfg.App {
+		inst, err := app.GetInstance()
+		if err != nil {
+			return err
+		}
+		if c, ok := inst.(*router.Config); ok {
+			rc = c
+		}
+	}
+	if rc == nil {
+		rc = &router.Config{}
+	}
+	rt := r.instance.GetFeature(routing.RouterType()).(routing.Router)
+	if err := rt.AddRule(serial.ToTypedMessage(rc), false); err != nil {
+		return fmt.Errorf("reload routing: %v", err)
+	}
+	return nil
+}
+
+// WatchConfigFile polls path every interval and calls onChange when its size or modification time changes.
+func WatchConfigFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	stamp := func() string {
+		st, err := os.Stat(path)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%d-%d", st.Size(), st.ModTime().UnixNano())
+	}
+	last := stamp()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cur := stamp()
+			if cur == "" || cur == last {
+				continue
+			}
+			last = cur
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : reload_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 08:06:39
+ * Description : Tests for hot reload: a failing step rolls back inbounds, users and outbounds.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/features/outbound"
+)
+
+// accepts reports whether something listens on the loopback port.
+func accepts(port int) bool {
+	c, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(port), time.Second)
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+func TestReloadRollsBackOnPortInUse(t *testing.T) {
+	t.Run("xray listeners", func(t *testing.T) { testReloadRollback(t, 0) })
+	// With drain on, inbounds sit behind gates and the taken port fails when the gate binds it.
+	t.Run("gated", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("drain is not supported on Windows")
+		}
+		t.Cleanup(removeInternalSockets)
+		testReloadRollback(t, 5)
+	})
+}
+
+func testReloadRollback(t *testing.T, drainSeconds int) {
+	echo := echoServer(t)
+	cfg := testServerConfig(t)
+	cfg.ListenPort = freePort(t)
+	cfg.Drain.TimeoutSeconds = drainSeconds
+	sidePort := freePort(t)
+	cfg.Inbounds = []models.InboundConfig{{Tag: "side", ListenAddress: "127.0.0.1", ListenPort: sidePort}}
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewXrayRunner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	// A new user (applied in place on the root inbound), a new outbound, then the side inbound moves to a taken port.
+	next := *cfg
+	next.Users = append(append([]models.ServerUser(nil), cfg.Users...), models.ServerUser{ID: "cd948868-b033-4ab7-85f7-eae730c69c88", Email: "bob@abdal"})
+	next.Outbounds = []models.OutboundConfig{{Tag: "extra", Type: "direct"}}
+	next.Inbounds = []models.InboundConfig{{Tag: "side", ListenAddress: "127.0.0.1", ListenPort: busy.Addr().(*net.TCPAddr).Port}}
+	if err := ValidateConfig(&next); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := r.Reload(&next)
+	if err == nil {
+		t.Fatalf("reload onto a taken port succeeded: %s", rep)
+	}
+	if rep != nil || r.config != cfg {
+		t.Errorf("failed reload kept changes: report %v", rep)
+	}
+
+	if !accepts(sidePort) {
+		t.Error("the old side listener no longer accepts")
+	}
+	om := r.instance.GetFeature(outbound.ManagerType()).(outbound.Manager)
+	if om.GetHandler("extra") != nil {
+		t.Error("the new outbound was not removed")
+	}
+	um, err := userManager(r.instance.GetFeature(inbound.ManagerType()).(inbound.Manager), primaryInboundTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if um.RemoveUser(context.Background(), "bob@abdal") == nil {
+		t.Error("the new user was not removed")
+	}
+	for name, port := range map[string]int{"root": cfg.ListenPort, "side": sidePort} {
+		if !roundTrip(tunnel(t, port, "tcp", echo), name) {
+			t.Errorf("%s inbound does not carry tunnels after the rollback", name)
+		}
+	}
+
+	// The rolled back state is consistent: the same change onto a free port applies.
+	next.Inbounds[0].ListenPort = freePort(t)
+	if _, err := r.Reload(&next); err != nil {
+		t.Fatal(err)
+	}
+	if accepts(sidePort) || !accepts(next.Inbounds[0].ListenPort) {
+		t.Error("side inbound did not move")
+	}
+	if om.GetHandler("extra") == nil {
+		t.Error("the new outbound is missing")
+	}
+}
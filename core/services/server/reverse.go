@@ -0,0 +1,171 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : reverse.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 02:31:08
+ * Description : Reverse tunnels: server listeners forwarded through Xray portals to the bridges of connected clients.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/services/client"
+	"github.com/xtls/xray-core/app/reverse"
+	"github.com/xtls/xray-core/features/outbound"
+)
+
+// xrayDokodemo is the settings of a reverse tunnel listener. The destination is a placeholder:
+// the client sends every connection of the tunnel to its own target.
+type xrayDokodemo struct {
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	Network string `json:"network"`
+}
+
+// buildReverse returns the listeners of the reverse tunnels and the routing rules (evaluated first) that
+// send their connections, and the bridge connection of each tunnel's user, to the tunnel's portal.
+// Bridge connections of other users are blocked.
+func buildReverse(cfg *models.ServerConfig, inbounds []xrayInbound, outbounds []xrayOutbound) ([]xrayInbound, []xrayRule, error) {
+	used := make(map[string]bool, len(inbounds)+len(outbounds))
+	for _, in := range inbounds {
+		used[in.Tag] = true
+	}
+	for _, o := range outbounds {
+		used[o.Tag] = true
+	}
+	var listeners []xrayInbound
+	var rules []xrayRule
+	for i, rt := range cfg.Reverse {
+		domain, err := client.ReverseDomain(rt.Name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reverse[%d]: %v", i, err)
+		}
+		tag := client.ReverseTag(rt.Name)
+		if used[tag] {
+			return nil, nil, fmt.Errorf("reverse %s: tag %q is used twice or by an inbound or outbound", rt.Name, tag)
+		}
+		used[tag] = true
+		host, portStr, err := net.SplitHostPort(rt.Listen)
+		port, perr := strconv.Atoi(portStr)
+		if err != nil || perr != nil || port < 1 || port > 65535 {
+			return nil, nil, fmt.Errorf("reverse %s: listen %q: must be host:port", rt.Name, rt.Listen)
+		}
+		if host == "" {
+			host = "0.0.0.0"
+		}
+		if rt.User == "" {
+			return nil, nil, fmt.Errorf("reverse %s: user is required", rt.Name)
+		}
+		if !gateAll(cfg) { // else the gate listens and dispatches as tag, see planGates
+			listeners = append(listeners, xrayInbound{
+				Listen:   host,
+				Port:     port,
+				Protocol: "dokodemo-door",
+				Tag:      tag,
+				Settings: &xrayDokodemo{Address: "127.0.0.1", Port: port, Network: "tcp"},
+			})
+		}
+		rules = append(rules,
+			xrayRule{Type: "field", InboundTag: []string{tag}, OutboundTag: tag},
+			xrayRule{Type: "field", Domain: []string{"full:" + domain}, User: []string{rt.User}, OutboundTag: tag},
+			xrayRule{Type: "field", Domain: []string{"full:" + domain}, OutboundTag: outboundBlock}, // other users' bridges
+		)
+	}
+	return listeners, rules, nil
+}
+
+// startPortals starts a portal for every reverse tunnel of cfg that has none yet and returns their names.
+func (r *XrayRunner) startPortals(cfg *models.ServerConfig) ([]string, error) {
+	om, ok := r.instance.GetFeature(outbound.ManagerType()).(outbound.Manager)
+	if !ok {
+		return nil, fmt.Errorf("reverse: outbound manager not found")
+	}
+	if r.portals == nil {
+		r.portals = make(map[string]*reverse.Portal)
+	}
+	var started []string
+	for _, rt := range cfg.Reverse {
+		if r.portals[rt.Name] != nil {
+			continue
+		}
+		domain, err := client.ReverseDomain(rt.Name)
+		if err != nil {
+			return started, err
+		}
+		p, err := reverse.NewPortal(&reverse.PortalConfig{Tag: client.ReverseTag(rt.Name), Domain: domain}, om)
+		if err != nil {
+			return started, fmt.Errorf("reverse %s: %v", rt.Name, err)
+		}
+		if err := p.Start(); err != nil {
+			return started, fmt.Errorf("reverse %s: %v", rt.Name, err)
+		}
+		r.portals[rt.Name] = p
+		started = append(started, rt.Name)
+	}
+	return started, nil
+}
+
+// closePortals closes the portals of reverse tunnels that cfg no longer has and returns their names.
+func (r *XrayRunner) closePortals(cfg *models.ServerConfig) []string {
+	keep := make(map[string]bool, len(cfg.Reverse))
+	for _, rt := range cfg.Reverse {
+		keep[rt.Name] = true
+	}
+	var closed []string
+	for name, p := range r.portals {
+		if keep[name] {
+			continue
+		}
+		_ = p.Close()
+		delete(r.portals, name)
+		closed = append(closed, name)
+	}
+	sort.Strings(closed)
+	return closed
+}
+
+// closeReverseGates closes the gates of reverse tunnels that cfg removes, moves or no longer gates and returns their tags.
+func (r *XrayRunner) closeReverseGates(cfg *models.ServerConfig) map[string]bool {
+	keep := make(map[string]string, len(cfg.Reverse))
+	if gateAll(cfg) {
+		for _, rt := range cfg.Reverse {
+			keep[client.ReverseTag(rt.Name)] = rt.Listen
+		}
+	}
+	closed := make(map[string]bool)
+	for _, rt := range r.config.Reverse {
+		tag := client.ReverseTag(rt.Name)
+		if gates := r.gates[tag]; len(gates) > 0 && gates[0].plan.listenAddr != keep[tag] {
+			r.closeGates(tag)
+			closed[tag] = true
+		}
+	}
+	return closed
+}
+
+// reverseGatesToStart returns the tags of reverse tunnels of cfg that have no gate running
+// (startGates only starts those that cfg gates).
+func (r *XrayRunner) reverseGatesToStart(cfg *models.ServerConfig) map[string]bool {
+	tags := make(map[string]bool, len(cfg.Reverse))
+	for _, rt := range cfg.Reverse {
+		if tag := client.ReverseTag(rt.Name); len(r.gates[tag]) == 0 {
+			tags[tag] = true
+		}
+	}
+	return tags
+}
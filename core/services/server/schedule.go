@@ -0,0 +1,252 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : schedule.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 21:34:52
+ * Description : Time-of-day access schedules: users are refused outside their windows and kicked when a window ends.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // timezones on hosts without a zoneinfo database (Windows)
+
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// scheduleCheckInterval is how often window ends are checked to close the sessions of disabled users.
+const scheduleCheckInterval = 10 * time.Second
+
+// Scheduler is a tracker observer that enforces the access schedules of users.
+type Scheduler struct {
+	schedules map[string]*userSchedule // by email
+	enabled   map[string]bool          // last known state, for transition logs
+	tracker   *conntrack.Tracker
+	mu        sync.Mutex
+}
+
+// userSchedule is a parsed models.UserSchedule.
+type userSchedule struct {
+	loc     *time.Location
+	windows []scheduleWindow
+}
+
+type scheduleWindow struct {
+	days       [7]bool // indexed by time.Weekday
+	start, end int     // minutes since midnight
+}
+
+var scheduleDays = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekend":  {time.Saturday, time.Sunday},
+}
+
+// parseSchedule validates and compiles s.
+func parseSchedule(s *models.UserSchedule) (*userSchedule, error) {
+	loc := time.Local
+	if s.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("timezone %q: %v", s.Timezone, err)
+		}
+	}
+	if len(s.Windows) == 0 {
+		return nil, fmt.Errorf("windows: at least one window is required")
+	}
+	us := &userSchedule{loc: loc}
+	for i, w := range s.Windows {
+		var sw scheduleWindow
+		if len(w.Days) == 0 {
+			sw.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		for _, d := range w.Days {
+			days, ok := scheduleDays[strings.ToLower(d)]
+			if !ok {
+				return nil, fmt.Errorf("windows[%d]: day %q: must be mon..sun, weekdays or weekend", i, d)
+			}
+			for _, wd := range days {
+				sw.days[wd] = true
+			}
+		}
+		var err error
+		if sw.start, err = parseClock(w.Start); err != nil || sw.start == 24*60 {
+			return nil, fmt.Errorf("windows[%d]: start %q: must be HH:MM", i, w.Start)
+		}
+		if sw.end, err = parseClock(w.End); err != nil {
+			return nil, fmt.Errorf("windows[%d]: end %q: must be HH:MM", i, w.End)
+		}
+		us.windows = append(us.windows, sw)
+	}
+	return us, nil
+}
+
+// parseClock parses "HH:MM" (00:00 to 24:00) into minutes since midnight.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if n, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || n != 2 || len(s) != 5 {
+		return 0, fmt.Errorf("bad time")
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("bad time")
+	}
+	return h*60 + m, nil
+}
+
+// allows reports whether t falls in one of the windows.
+func (us *userSchedule) allows(t time.Time) bool {
+	t = t.In(us.loc)
+	day := t.Weekday()
+	prev := (day + 6) % 7
+	min := t.Hour()*60 + t.Minute()
+	for _, w := range us.windows {
+		switch {
+		case w.start < w.end:
+			if w.days[day] && min >= w.start && min < w.end {
+				return true
+			}
+		case w.start == w.end: // whole day
+			if w.days[day] {
+				return true
+			}
+		default: // overnight
+			if (w.days[day] && min >= w.start) || (w.days[prev] && min < w.end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkSchedules validates the schedules of all users.
+func checkSchedules(cfg *models.ServerConfig) error {
+	for _, u := range cfg.Users {
+		if u.Schedule == nil {
+			continue
+		}
+		if _, err := parseSchedule(u.Schedule); err != nil {
+			return fmt.Errorf("user %s: schedule %v", u.Email, err)
+		}
+	}
+	return nil
+}
+
+// schedulesChanged reports whether the schedule of any user kept in cfg differs from old.
+func schedulesChanged(old, cfg *models.ServerConfig) bool {
+	before := make(map[string]*models.UserSchedule, len(old.Users))
+	for _, u := range old.Users {
+		before[u.Email] = u.Schedule
+	}
+	for _, u := range cfg.Users {
+		if prev, ok := before[u.Email]; ok && !reflect.DeepEqual(prev, u.Schedule) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply sets the schedules from cfg and closes the sessions of users that are now outside their window.
+func (s *Scheduler) Apply(cfg *models.ServerConfig, tracker *conntrack.Tracker) {
+	schedules := make(map[string]*userSchedule)
+	for _, u := range cfg.Users {
+		if u.Schedule == nil {
+			continue
+		}
+		if us, err := parseSchedule(u.Schedule); err == nil {
+			schedules[u.Email] = us
+		}
+	}
+	s.mu.Lock()
+	s.schedules, s.tracker = schedules, tracker
+	if s.enabled == nil {
+		s.enabled = make(map[string]bool)
+	}
+	for email := range s.enabled {
+		if schedules[email] == nil {
+			delete(s.enabled, email)
+		}
+	}
+	s.mu.Unlock()
+	s.check(time.Now())
+}
+
+// run checks the schedules until ctx is cancelled.
+func (s *Scheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.check(now)
+		}
+	}
+}
+
+// check logs users entering or leaving their windows and closes the connections of those outside.
+func (s *Scheduler) check(now time.Time) {
+	var disabled []string
+	s.mu.Lock()
+	for email, us := range s.schedules {
+		on := us.allows(now)
+		was, known := s.enabled[email]
+		s.enabled[email] = on
+		switch {
+		case on && known && !was:
+			logger.Infof("schedule: %s enabled (inside its access window)", email)
+		case !on && (!known || was):
+			logger.Infof("schedule: %s disabled (outside its access window)", email)
+		}
+		if !on {
+			disabled = append(disabled, email)
+		}
+	}
+	tracker := s.tracker
+	s.mu.Unlock()
+	if tracker == nil {
+		return
+	}
+	for _, email := range disabled {
+		if n := tracker.CloseWhere(func(c *conntrack.Conn) bool { return c.Email == email }); n > 0 {
+			logger.Infof("schedule: closed %d connections of %s", n, email)
+		}
+	}
+}
+
+// Opened implements conntrack.Observer.
+func (s *Scheduler) Opened(c *conntrack.Conn) error {
+	s.mu.Lock()
+	us := s.schedules[c.Email]
+	s.mu.Unlock()
+	if us != nil && !us.allows(time.Now()) {
+		return conntrack.Reject("schedule", "%s: outside access schedule", c.Email)
+	}
+	return nil
+}
+
+// Closed implements conntrack.Observer.
+func (s *Scheduler) Closed(c *conntrack.Conn, err error) {}
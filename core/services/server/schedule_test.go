@@ -0,0 +1,119 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : schedule_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 05:31:18
+ * Description : Tests for access schedule windows: overnight ranges, 24:00, weekday rollover and timezones.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// scheduleAt returns the UTC time on the given day of the week of 2026-10-12 (Monday) at HH:MM.
+func scheduleAt(day time.Weekday, clock string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", "2026-10-12 "+clock)
+	if err != nil {
+		panic(err)
+	}
+	return t.AddDate(0, 0, (int(day)+6)%7)
+}
+
+func TestScheduleAllows(t *testing.T) {
+	window := func(start, end string, days ...string) models.ScheduleWindow {
+		return models.ScheduleWindow{Days: days, Start: start, End: end}
+	}
+	tests := []struct {
+		name    string
+		windows []models.ScheduleWindow
+		t       time.Time
+		want    bool
+	}{
+		{"inside a day window", []models.ScheduleWindow{window("09:00", "17:00", "weekdays")}, scheduleAt(time.Wednesday, "09:00"), true},
+		{"end is exclusive", []models.ScheduleWindow{window("09:00", "17:00", "weekdays")}, scheduleAt(time.Wednesday, "17:00"), false},
+		{"other day", []models.ScheduleWindow{window("09:00", "17:00", "weekdays")}, scheduleAt(time.Saturday, "12:00"), false},
+		{"no days means every day", []models.ScheduleWindow{window("09:00", "17:00")}, scheduleAt(time.Sunday, "12:00"), true},
+
+		{"overnight, before midnight", []models.ScheduleWindow{window("22:00", "06:00", "fri")}, scheduleAt(time.Friday, "23:30"), true},
+		{"overnight, after midnight on the next day", []models.ScheduleWindow{window("22:00", "06:00", "fri")}, scheduleAt(time.Saturday, "05:59"), true},
+		{"overnight, ended", []models.ScheduleWindow{window("22:00", "06:00", "fri")}, scheduleAt(time.Saturday, "06:00"), false},
+		{"overnight, not started", []models.ScheduleWindow{window("22:00", "06:00", "fri")}, scheduleAt(time.Friday, "21:59"), false},
+		{"overnight, early morning of the listed day", []models.ScheduleWindow{window("22:00", "06:00", "fri")}, scheduleAt(time.Friday, "03:00"), false},
+		{"overnight, starting the next evening", []models.ScheduleWindow{window("22:00", "06:00", "fri")}, scheduleAt(time.Saturday, "22:00"), false},
+		{"overnight rolls over from saturday to sunday", []models.ScheduleWindow{window("20:00", "02:00", "sat")}, scheduleAt(time.Sunday, "01:00"), true},
+		{"overnight rolls over from sunday to monday", []models.ScheduleWindow{window("20:00", "02:00", "weekend")}, scheduleAt(time.Monday, "01:59"), true},
+		{"weekend overnight does not reach tuesday", []models.ScheduleWindow{window("20:00", "02:00", "weekend")}, scheduleAt(time.Tuesday, "01:00"), false},
+
+		{"24:00, last minute", []models.ScheduleWindow{window("18:00", "24:00", "mon")}, scheduleAt(time.Monday, "23:59"), true},
+		{"24:00, stops at midnight", []models.ScheduleWindow{window("18:00", "24:00", "mon")}, scheduleAt(time.Tuesday, "00:00"), false},
+		{"00:00 to 24:00 is the whole day", []models.ScheduleWindow{window("00:00", "24:00", "sun")}, scheduleAt(time.Sunday, "00:00"), true},
+		{"start equal to end is the whole day", []models.ScheduleWindow{window("08:00", "08:00", "tue")}, scheduleAt(time.Tuesday, "03:00"), true},
+		{"whole day stays on its day", []models.ScheduleWindow{window("08:00", "08:00", "tue")}, scheduleAt(time.Wednesday, "03:00"), false},
+
+		{"second window", []models.ScheduleWindow{window("09:00", "12:00"), window("13:00", "17:00")}, scheduleAt(time.Monday, "14:00"), true},
+		{"between windows", []models.ScheduleWindow{window("09:00", "12:00"), window("13:00", "17:00")}, scheduleAt(time.Monday, "12:30"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us, err := parseSchedule(&models.UserSchedule{Timezone: "UTC", Windows: tt.windows})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := us.allows(tt.t); got != tt.want {
+				t.Errorf("allows(%s) = %v, want %v", tt.t.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleTimezone(t *testing.T) {
+	// Asia/Tehran is UTC+03:30 all year.
+	us, err := parseSchedule(&models.UserSchedule{Timezone: "Asia/Tehran", Windows: []models.ScheduleWindow{{Days: []string{"sat"}, Start: "00:00", End: "02:00"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 21:00 UTC on Friday is 00:30 on Saturday in Tehran.
+	if !us.allows(scheduleAt(time.Friday, "21:00")) {
+		t.Error("window not applied in its timezone")
+	}
+	if us.allows(scheduleAt(time.Saturday, "01:00")) {
+		t.Error("window applied in UTC")
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name string
+		s    models.UserSchedule
+	}{
+		{"no windows", models.UserSchedule{}},
+		{"unknown timezone", models.UserSchedule{Timezone: "Mars/Olympus", Windows: []models.ScheduleWindow{{Start: "09:00", End: "17:00"}}}},
+		{"unknown day", models.UserSchedule{Windows: []models.ScheduleWindow{{Days: []string{"monday"}, Start: "09:00", End: "17:00"}}}},
+		{"start at 24:00", models.UserSchedule{Windows: []models.ScheduleWindow{{Start: "24:00", End: "06:00"}}}},
+		{"past 24:00", models.UserSchedule{Windows: []models.ScheduleWindow{{Start: "22:00", End: "24:01"}}}},
+		{"minutes out of range", models.UserSchedule{Windows: []models.ScheduleWindow{{Start: "09:60", End: "17:00"}}}},
+		{"not HH:MM", models.UserSchedule{Windows: []models.ScheduleWindow{{Start: "9:00", End: "17:00"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := parseSchedule(&tt.s); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+	if _, err := parseSchedule(&models.UserSchedule{Windows: []models.ScheduleWindow{{Days: []string{"Weekdays", "SAT"}, Start: "00:00", End: "24:00"}}}); err != nil {
+		t.Errorf("mixed-case days: %v", err)
+	}
+}
@@ -6,7 +6,7 @@
  * Author : Ebrahim Shafiei (EbraSha)
  * Email : Prof.Shafiei@Gmail.com
  * Created On : 2026-02-14 22:16:06
- * Description : Server orchestration: loads config, starts Xray (VLESS+Reality+gRPC).
+ * Description : Server orchestration: loads config, starts Xray (VLESS+Reality+gRPC), applies hot reloads.
  * -------------------------------------------------------------------
  *
  * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
@@ -20,22 +20,403 @@ package server
 import (
 	"context"
 	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+	"time"
 
-	"github.com/ebrasha/abdal-gost-proxy/core/colors"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/metrics"
 	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/netutil"
+	"github.com/ebrasha/abdal-gost-proxy/core/security"
+	"github.com/ebrasha/abdal-gost-proxy/core/webhook"
 )
 
+// Server runs the Xray runner, the optional decoy site and metrics endpoint, and applies hot reloads.
+type Server struct {
+	cfg      *models.ServerConfig
+	runner   *XrayRunner
+	registry *metrics.Registry
+	traffic  *metrics.TrafficCollector
+	access   AccessLog
+	ipLimit  IPLimiter
+	throttle Throttler
+	schedule Scheduler
+	quota    QuotaTracker
+	hooks    *webhook.Dispatcher
+	policy   PolicyCounter
+	rates    rateSampler
+	sideServices
+	mu sync.Mutex
+}
+
+// sideServices are the listeners served next to Xray.
+type sideServices struct {
+	decoy      *DecoySite
+	metricsSrv *metrics.Server
+	pusher     *metrics.Pusher
+	control    *controlServer
+}
+
+// NewServer builds the Xray runner from cfg (does not start).
+func NewServer(cfg *models.ServerConfig) (*Server, error) {
+	runner, err := NewXrayRunner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{cfg: cfg, runner: runner, registry: metrics.NewRegistry(), hooks: webhook.NewDispatcher()}
+	s.registry.RegisterBuildInfo("server")
+	s.registry.RegisterRuntime()
+	s.traffic = s.registry.NewTrafficCollector("abdal_server", true)
+	s.registry.Register(&s.policy)
+	if err := s.access.Apply(&cfg.AccessLog, &cfg.Log); err != nil {
+		return nil, err
+	}
+	if err := s.hooks.Apply(webhookOptions(cfg.Webhooks)); err != nil {
+		return nil, err
+	}
+	s.ipLimit.hooks = s.hooks
+	s.quota.hooks = s.hooks
+	if err := s.quota.Apply(cfg, runner.Tracker()); err != nil {
+		return nil, err
+	}
+	s.ipLimit.Apply(cfg)
+	s.throttle.Apply(cfg)
+	s.schedule.Apply(cfg, runner.Tracker())
+	runner.onReject = s.traffic.Rejected
+	runner.Tracker().AddObserver(&s.policy)
+	runner.Tracker().AddObserver(&s.schedule)
+	runner.Tracker().AddObserver(&s.quota)
+	runner.Tracker().AddObserver(&s.ipLimit)
+	runner.Tracker().AddObserver(&s.throttle)
+	runner.Tracker().AddObserver(&s.access)
+	runner.Tracker().AddObserver(s.traffic)
+	runner.Tracker().SetExactBytes(exactBytes(cfg))
+	return s, nil
+}
+
 // Run starts the Abdal Gost Proxy server (VLESS + Reality + gRPC on listen_port).
 func Run(ctx context.Context, cfg *models.ServerConfig) error {
-	runner, err := NewXrayRunner(cfg)
+	s, err := NewServer(cfg)
+	if err != nil {
+		return err
+	}
+	return s.Run(ctx)
+}
+
+// Run starts the decoy site and Xray; blocks until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	s.mu.Lock()
+	cfg := s.cfg
+	if cfg.Upgrade.Enabled {
+		pidFile := pidFileOf(cfg)
+		if err := writePIDFile(pidFile); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		defer removePIDFile(pidFile)
+	}
+	defer func() {
+		s.quota.save() // after the drain, so the last bytes are counted
+		s.mu.Lock()
+		closeServices(s.sideServices, sideServices{})
+		_ = s.access.Close()
+		s.mu.Unlock()
+		s.hooks.Close()
+	}()
+	side, err := s.openServices(&models.ServerConfig{}, cfg)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.sideServices = side
+	s.mu.Unlock()
+	go s.schedule.run(ctx)
+	go s.quota.run(ctx)
+	go s.probeDests(ctx)
+	defer func() {
+		if err := s.runner.Close(); err != nil {
+			logger.Warnf("close: %v", err)
+		}
+	}()
+	if err := printInbounds(cfg); err != nil {
+		return err
+	}
+	return s.runner.Start(ctx)
+}
+
+// exactBytes reports whether metrics, the access log, throttling or quotas need every byte to pass the tracked link (no XTLS splice).
+func exactBytes(cfg *models.ServerConfig) bool {
+	return cfg.Metrics.Listen != "" || cfg.Metrics.Push.Address != "" || cfg.AccessLog.Enabled || throttled(cfg) || metered(cfg)
+}
+
+// openServices opens the side services whose settings differ between old and cfg and carries over the others.
+// Nothing running is closed: a replacement on an unchanged address takes over the running socket.
+func (s *Server) openServices(old, cfg *models.ServerConfig) (next sideServices, err error) {
+	next = s.sideServices
+	var offered []string
+	offer := func(key string, file func() (*os.File, error)) {
+		offerListener(key, file)
+		offered = append(offered, key)
+	}
+	defer func() {
+		for _, key := range offered {
+			withdrawListener(key)
+		}
+		if err != nil {
+			s.discardServices(next)
+			next = sideServices{}
+		}
+	}()
+	if !reflect.DeepEqual(old.Decoy, cfg.Decoy) {
+		if s.decoy != nil {
+			offer(decoyListenerKey(s.decoy.Addr()), s.decoy.File)
+		}
+		next.decoy = nil
+		if cfg.Decoy.Enabled {
+			if next.decoy, err = NewDecoySite(&cfg.Decoy); err != nil {
+				return next, fmt.Errorf("decoy: %v", err)
+			}
+			logger.Infof("decoy site on %s (fallback)", next.decoy.Addr())
+		}
+	}
+	if old.Metrics != cfg.Metrics {
+		if s.metricsSrv != nil {
+			offer(listenerKey("tcp", old.Metrics.Listen), s.metricsSrv.File)
+		}
+		if next.metricsSrv, next.pusher, err = openMetrics(&cfg.Metrics, s.registry); err != nil {
+			return next, err
+		}
+	}
+	if old.Control != cfg.Control {
+		if s.control != nil {
+			offer(controlListenerKey(old.Control.Listen), s.control.File)
+		}
+		next.control = nil
+		if cfg.Control.Listen != "" {
+			if next.control, err = s.openControl(&cfg.Control); err != nil {
+				return next, err
+			}
+		}
+	}
+	return next, nil
+}
+
+// closeServices closes the services of from that to does not keep. A unix socket that to took over stays in place.
+func closeServices(from, to sideServices) {
+	if from.decoy != nil && from.decoy != to.decoy {
+		if to.decoy != nil && sameSocket(from.decoy.ln, to.decoy.ln) {
+			from.decoy.Detach()
+		}
+		_ = from.decoy.Close()
+	}
+	if from.metricsSrv != nil && from.metricsSrv != to.metricsSrv {
+		_ = from.metricsSrv.Close()
+	}
+	if from.pusher != nil && from.pusher != to.pusher {
+		_ = from.pusher.Close() // sends a last push
+	}
+	if from.control != nil && from.control != to.control {
+		if to.control != nil && sameSocket(from.control.ln, to.control.ln) {
+			from.control.Detach()
+		}
+		_ = from.control.Close()
+	}
+}
+
+// discardServices closes the services of next that are not running and re-applies the running mode and owner
+// to unix sockets they took over.
+func (s *Server) discardServices(next sideServices) {
+	closeServices(next, s.sideServices)
+	if s.decoy != nil && s.decoy != next.decoy && security.IsUnixSocketPath(s.decoy.Addr()) {
+		_ = netutil.SetSocketPerms(s.decoy.Addr(), s.cfg.Decoy.UnixSocket.Mode, s.cfg.Decoy.UnixSocket.Owner)
+	}
+	if s.control != nil && s.control != next.control && security.IsUnixSocketPath(s.cfg.Control.Listen) {
+		mode := s.cfg.Control.UnixSocket.Mode
+		if mode == "" {
+			mode = defaultControlSocketMode
+		}
+		_ = netutil.SetSocketPerms(s.cfg.Control.Listen, mode, s.cfg.Control.UnixSocket.Owner)
+	}
+}
+
+// sameSocket reports whether a and b listen on the same address.
+func sameSocket(a, b net.Listener) bool {
+	return a.Addr().Network() == b.Addr().Network() && a.Addr().String() == b.Addr().String()
+}
+
+// openMetrics serves /metrics (reusing an inherited or offered listener when present) and starts the UDP pusher, as configured.
+func openMetrics(cfg *models.MetricsConfig, reg *metrics.Registry) (*metrics.Server, *metrics.Pusher, error) {
+	var srv *metrics.Server
+	if cfg.Listen != "" {
+		ln, err := takeInheritedListener(listenerKey("tcp", cfg.Listen))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ln == nil {
+			if ln, err = net.Listen("tcp", cfg.Listen); err != nil {
+				return nil, nil, fmt.Errorf("metrics: %v", err)
+			}
+		}
+		srv = metrics.NewServer(ln, reg)
+		logger.Infof("metrics on http://%s/metrics", cfg.Listen)
+	}
+	var pusher *metrics.Pusher
+	if cfg.Push.Address != "" {
+		var err error
+		pusher, err = metrics.NewPusher(reg, metrics.PushOptions{
+			Address:  cfg.Push.Address,
+			Format:   cfg.Push.Format,
+			Interval: time.Duration(cfg.Push.IntervalSeconds) * time.Second,
+			Prefix:   "abdal_server_",
+		})
+		if err != nil {
+			if srv != nil {
+				_ = srv.Close()
+			}
+			return nil, nil, err
+		}
+		logger.Infof("pushing metrics to udp://%s", cfg.Push.Address)
+	}
+	return srv, pusher, nil
+}
+
+// printInbounds prints one line per listening inbound and reverse tunnel.
+func printInbounds(cfg *models.ServerConfig) error {
+	inbounds, err := resolveInbounds(cfg)
 	if err != nil {
 		return err
 	}
+	for i := range inbounds {
+		in := &inbounds[i]
+		network := in.cfg.Transport.Type
+		if network == "" {
+			network = "grpc"
+		}
+		logger.Infof("listening on %s (VLESS+%s+Reality, %s)", in.displayAddr(), network, in.tag)
+	}
+	for _, rt := range cfg.Reverse {
+		logger.Infof("reverse tunnel %s on %s (served by %s)", rt.Name, rt.Listen, rt.User)
+	}
+	return nil
+}
+
+// Reload validates cfg and applies it to the running server. Replacement listeners are opened before anything
+// running is changed, and a failed step rolls back the ones before it, so on error the old config keeps running
+// (except for changes Xray applied before it failed, see XrayRunner.Reload).
+func (s *Server) Reload(cfg *models.ServerConfig) (*ReloadReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	next, err := s.openServices(s.cfg, cfg)
+	if err != nil {
+		return nil, err
+	}
+	rep, err := s.runner.Reload(cfg)
+	if err != nil {
+		s.discardServices(next)
+		return rep, err
+	}
+	if err := s.applySettings(s.cfg, cfg, rep); err != nil {
+		s.discardServices(next)
+		if _, rerr := s.runner.Reload(s.cfg); rerr != nil {
+			logger.Errorf("reload: rolling back: %v", rerr)
+		}
+		return nil, err
+	}
+	closeServices(s.sideServices, next)
+	s.sideServices = next
+	if s.cfg.Metrics != cfg.Metrics {
+		rep.Settings = append(rep.Settings, "metrics")
+	}
+	if s.cfg.Control != cfg.Control {
+		rep.Settings = append(rep.Settings, "control")
+	}
+	if !reflect.DeepEqual(s.cfg.Decoy, cfg.Decoy) {
+		rep.Settings = append(rep.Settings, "decoy")
+	}
+	s.ipLimit.Apply(cfg)
+	if s.cfg.IPLimit != cfg.IPLimit {
+		rep.Settings = append(rep.Settings, "ip_limit")
+	}
+	s.throttle.Apply(cfg)
+	if bandwidthChanged(s.cfg, cfg) {
+		rep.Settings = append(rep.Settings, "bandwidth")
+	}
+	s.schedule.Apply(cfg, s.runner.Tracker())
+	if schedulesChanged(s.cfg, cfg) {
+		rep.Settings = append(rep.Settings, "schedule")
+	}
+	if quotasChanged(s.cfg, cfg) {
+		rep.Settings = append(rep.Settings, "quotas")
+	}
+	if !reflect.DeepEqual(s.cfg.DNS, cfg.DNS) {
+		logger.Warnf("reload: dns changes take effect after a restart")
+	}
+	s.runner.Tracker().SetExactBytes(exactBytes(cfg))
+	s.notifyUserChanges(s.cfg, cfg)
+	s.cfg = cfg
+	return rep, nil
+}
+
+// applySettings applies the reload steps that can fail (log and access log files, webhooks, the user store);
+// on error the steps already applied are reverted to old.
+func (s *Server) applySettings(old, cfg *models.ServerConfig, rep *ReloadReport) (err error) {
+	var undo []func()
 	defer func() {
-		if err := runner.Close(); err != nil {
-			fmt.Print(colors.Magenta(fmt.Sprintf("[Abdal Gost Proxy server] close: %v\n", err)))
+		if err != nil {
+			for i := len(undo) - 1; i >= 0; i-- {
+				undo[i]()
+			}
 		}
 	}()
-	fmt.Print(colors.Cyan(fmt.Sprintf("[Abdal Gost Proxy server] listening on %s:%d (VLESS+gRPC+Reality)\n", cfg.ListenAddress, cfg.ListenPort)))
-	return runner.Start(ctx)
+	if old.Log != cfg.Log {
+		if err := logger.Setup(&cfg.Log, "server"); err != nil {
+			return err
+		}
+		undo = append(undo, func() { _ = logger.Setup(&old.Log, "server") })
+		rep.Settings = append(rep.Settings, "log")
+	}
+	if old.AccessLog != cfg.AccessLog || old.Log != cfg.Log {
+		if err := s.access.Apply(&cfg.AccessLog, &cfg.Log); err != nil {
+			return err
+		}
+		undo = append(undo, func() { _ = s.access.Apply(&old.AccessLog, &old.Log) })
+		rep.Settings = append(rep.Settings, "access_log")
+	}
+	if !reflect.DeepEqual(old.Webhooks, cfg.Webhooks) {
+		if err := s.hooks.Apply(webhookOptions(cfg.Webhooks)); err != nil {
+			return err
+		}
+		undo = append(undo, func() { _ = s.hooks.Apply(webhookOptions(old.Webhooks)) })
+		rep.Settings = append(rep.Settings, "webhooks")
+	}
+	return s.quota.Apply(cfg, s.runner.Tracker()) // last: it has nothing to undo when it fails
+}
+
+// DrainStatus returns the progress of the current (or last) connection drain.
+func (s *Server) DrainStatus() netutil.DrainStatus {
+	return s.runner.DrainStatus()
+}
+
+// ReloadFile loads path and reloads the server, printing the outcome (SIGHUP and file watcher entry point).
+func (s *Server) ReloadFile(path string) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		logger.Errorf("reload rejected, keeping running config: %v", err)
+		s.hooks.Notify(eventReloadFailed, map[string]interface{}{"config": path, "error": err.Error()})
+		return
+	}
+	rep, err := s.Reload(cfg)
+	if err != nil {
+		logger.Errorf("reload failed: %v", err)
+		s.hooks.Notify(eventReloadFailed, map[string]interface{}{"config": path, "error": err.Error()})
+		return
+	}
+	logger.Infof("reloaded %s: %s", path, rep)
+	s.hooks.Notify(eventReloadSucceeded, map[string]interface{}{"config": path, "changes": rep.String()})
 }
@@ -0,0 +1,149 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : sessions.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 19:37:50
+ * Description : Active sessions per user (source IP, since, destinations, live throughput) and kicking users.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+)
+
+// Session groups the open connections of one user from one source IP.
+type Session struct {
+	SourceIP     string    `json:"source_ip"`
+	Since        time.Time `json:"since"` // start of the oldest open connection
+	Connections  int       `json:"connections"`
+	Destinations []string  `json:"destinations"`
+	UpBytes      int64     `json:"up_bytes"`
+	DownBytes    int64     `json:"down_bytes"`
+	UpBps        float64   `json:"up_bps"` // bytes per second over the last sample interval
+	DownBps      float64   `json:"down_bps"`
+}
+
+// UserSessions lists the sessions of one user.
+type UserSessions struct {
+	User        string    `json:"user"`
+	Connections int       `json:"connections"`
+	Sessions    []Session `json:"sessions"`
+}
+
+// ConnectionsReport is the answer of the connections command.
+type ConnectionsReport struct {
+	Users []UserSessions `json:"users"`
+}
+
+// rateSampler turns byte counters into per-connection throughput between two samples.
+type rateSampler struct {
+	last map[*conntrack.Conn]rateSample
+	mu   sync.Mutex
+}
+
+type rateSample struct {
+	up, down     int64
+	at           time.Time
+	upBps, dnBps float64
+}
+
+// sample records the counters of conns and forgets closed connections.
+func (r *rateSampler) sample(conns []*conntrack.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	next := make(map[*conntrack.Conn]rateSample, len(conns))
+	for _, c := range conns {
+		cur := rateSample{up: c.Uplink(), down: c.Downlink(), at: now}
+		if prev, ok := r.last[c]; ok {
+			if secs := now.Sub(prev.at).Seconds(); secs > 0 {
+				cur.upBps = float64(cur.up-prev.up) / secs
+				cur.dnBps = float64(cur.down-prev.down) / secs
+			}
+		}
+		next[c] = cur
+	}
+	r.last = next
+}
+
+// rates returns the last measured throughput of c (zero until it has been sampled twice).
+func (r *rateSampler) rates(c *conntrack.Conn) (up, down float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.last[c]
+	return s.upBps, s.dnBps
+}
+
+// buildConnectionsReport groups conns by user and source IP; user filters to one user when not empty.
+func buildConnectionsReport(conns []*conntrack.Conn, rates *rateSampler, user string) *ConnectionsReport {
+	type key struct{ user, ip string }
+	sessions := make(map[key]*Session)
+	dests := make(map[key]map[string]bool)
+	for _, c := range conns {
+		if user != "" && c.Email != user {
+			continue
+		}
+		k := key{c.Email, c.SourceIP}
+		s, ok := sessions[k]
+		if !ok {
+			s = &Session{SourceIP: c.SourceIP, Since: c.Started}
+			sessions[k] = s
+			dests[k] = make(map[string]bool)
+		}
+		if c.Started.Before(s.Since) {
+			s.Since = c.Started
+		}
+		s.Connections++
+		s.UpBytes += c.Uplink()
+		s.DownBytes += c.Downlink()
+		up, down := rates.rates(c)
+		s.UpBps += up
+		s.DownBps += down
+		if c.Target != "" && !dests[k][c.Target] {
+			dests[k][c.Target] = true
+			s.Destinations = append(s.Destinations, c.Target)
+		}
+	}
+	byUser := make(map[string]*UserSessions)
+	for k, s := range sessions {
+		sort.Strings(s.Destinations)
+		u, ok := byUser[k.user]
+		if !ok {
+			u = &UserSessions{User: k.user}
+			byUser[k.user] = u
+		}
+		u.Connections += s.Connections
+		u.Sessions = append(u.Sessions, *s)
+	}
+	rep := &ConnectionsReport{Users: []UserSessions{}}
+	for _, u := range byUser {
+		sort.Slice(u.Sessions, func(i, j int) bool { return u.Sessions[i].Since.Before(u.Sessions[j].Since) })
+		rep.Users = append(rep.Users, *u)
+	}
+	sort.Slice(rep.Users, func(i, j int) bool { return rep.Users[i].User < rep.Users[j].User })
+	return rep
+}
+
+// Connections returns the active sessions, optionally of one user only.
+func (s *Server) Connections(user string) *ConnectionsReport {
+	return buildConnectionsReport(s.runner.Tracker().Conns(), &s.rates, user)
+}
+
+// Kick closes every open connection of user and returns how many were closed.
+func (s *Server) Kick(user string) int {
+	return s.runner.Tracker().CloseWhere(func(c *conntrack.Conn) bool { return c.Email == user })
+}
@@ -0,0 +1,183 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : upgrade.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 14:11:48
+ * Description : Zero-downtime upgrade support shared by all platforms: inherited listeners, readiness, PID file.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+const (
+	// DefaultPIDFile is the PID file written when upgrade.pid_file is empty.
+	DefaultPIDFile = "abdal-gost-proxy-server.pid"
+	// envInheritedListeners maps listener keys to inherited fds (JSON object), set by the old process.
+	envInheritedListeners = "ABDAL_GOST_INHERITED_LISTENERS"
+	// envUpgradeReadyFD is the fd the new process writes to once it serves traffic.
+	envUpgradeReadyFD = "ABDAL_GOST_UPGRADE_READY_FD"
+	// upgradeReadyTimeout bounds how long the old process waits for the new one to start.
+	upgradeReadyTimeout = 60 * time.Second
+)
+
+// inherited holds the listeners passed in by the previous process, consumed once each by key.
+var inherited struct {
+	once  sync.Once
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+// listenerKey identifies a listener across processes ("tcp:0.0.0.0:443", "unix:/run/abdal.sock").
+func listenerKey(network, addr string) string {
+	return network + ":" + addr
+}
+
+func loadInheritedListeners() {
+	inherited.files = make(map[string]*os.File)
+	raw := os.Getenv(envInheritedListeners)
+	if raw == "" {
+		return
+	}
+	_ = os.Unsetenv(envInheritedListeners)
+	var fds map[string]int
+	if err := json.Unmarshal([]byte(raw), &fds); err != nil {
+		return
+	}
+	for key, fd := range fds {
+		inherited.files[key] = os.NewFile(uintptr(fd), key)
+	}
+}
+
+// takeInheritedListener returns the inherited listener for key, or nil when there is none.
+func takeInheritedListener(key string) (net.Listener, error) {
+	inherited.once.Do(loadInheritedListeners)
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	f, ok := inherited.files[key]
+	if !ok {
+		return nil, nil
+	}
+	delete(inherited.files, key)
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited listener %s: %v", key, err)
+	}
+	return ln, nil
+}
+
+// offerListener makes a duplicate of a running listener available to takeInheritedListener, so its
+// replacement on reload takes over the socket instead of binding the address a second time.
+// Where sockets cannot be duplicated (Windows), nothing is offered and the replacement binds itself.
+func offerListener(key string, file func() (*os.File, error)) {
+	f, err := file()
+	if err != nil {
+		return
+	}
+	inherited.once.Do(loadInheritedListeners)
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	if prev, ok := inherited.files[key]; ok {
+		_ = prev.Close()
+	}
+	inherited.files[key] = f
+}
+
+// withdrawListener closes the offered listener of key unless it was taken.
+func withdrawListener(key string) {
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	if f, ok := inherited.files[key]; ok {
+		_ = f.Close()
+		delete(inherited.files, key)
+	}
+}
+
+// closeUnusedInherited closes inherited listeners the new config no longer uses.
+func closeUnusedInherited() {
+	inherited.once.Do(loadInheritedListeners)
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	for key, f := range inherited.files {
+		_ = f.Close()
+		delete(inherited.files, key)
+	}
+}
+
+// notifyUpgradeReady tells the old process (if any) that this process is serving; it then stops accepting.
+func notifyUpgradeReady() {
+	raw := os.Getenv(envUpgradeReadyFD)
+	if raw == "" {
+		return
+	}
+	_ = os.Unsetenv(envUpgradeReadyFD)
+	fd, err := strconv.Atoi(raw)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "upgrade-ready")
+	_, _ = f.Write([]byte{1})
+	_ = f.Close()
+}
+
+// pidFileOf returns the configured PID file path or the default.
+func pidFileOf(cfg *models.ServerConfig) string {
+	if cfg.Upgrade.PIDFile == "" {
+		return DefaultPIDFile
+	}
+	return cfg.Upgrade.PIDFile
+}
+
+// drainTimeoutOf returns upgrade.drain_timeout_seconds or the default of 30s.
+func drainTimeoutOf(cfg *models.ServerConfig) time.Duration {
+	if cfg.Upgrade.DrainTimeoutSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(cfg.Upgrade.DrainTimeoutSeconds) * time.Second
+}
+
+// writePIDFile records this process as the one serving the config.
+func writePIDFile(path string) error {
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// removePIDFile removes path unless a newer process has already taken it over.
+func removePIDFile(path string) {
+	if pid, err := readPIDFile(path); err == nil && pid == os.Getpid() {
+		_ = os.Remove(path)
+	}
+}
+
+// readPIDFile returns the PID stored in path.
+func readPIDFile(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("pid file %s: %v", path, err)
+	}
+	return pid, nil
+}
@@ -0,0 +1,187 @@
+//go:build !windows
+
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : upgrade_other.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 14:32:09
+ * Description : Zero-downtime binary upgrade on SIGUSR2: starts the new binary with the listening sockets, then drains.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// HandleUpgrades waits for SIGUSR2, hands the listeners to a freshly started binary, drains and then calls done.
+// A failed upgrade leaves this process serving as before.
+func (s *Server) HandleUpgrades(ctx context.Context, done func()) {
+	usr2 := make(chan os.Signal, 1)
+	signal.Notify(usr2, syscall.SIGUSR2)
+	defer signal.Stop(usr2)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-usr2:
+		}
+		s.mu.Lock()
+		cfg := s.cfg
+		s.mu.Unlock()
+		if !cfg.Upgrade.Enabled {
+			logger.Errorf("SIGUSR2 ignored: upgrade.enabled is false")
+			continue
+		}
+		pid, err := s.handover()
+		if err != nil {
+			logger.Errorf("upgrade failed, keeping this process: %v", err)
+			continue
+		}
+		timeout := drainTimeoutOf(cfg)
+		logger.Infof("new process %d is serving; draining for up to %s", pid, timeout)
+		forced := s.runner.drain(timeout, printDrainProgress)
+		logger.Infof("drain finished (%d connections force-closed); exiting", forced)
+		done()
+		return
+	}
+}
+
+// handover starts the current executable with the listening sockets and waits until it reports ready;
+// this process then stops accepting. Returns the new process ID.
+func (s *Server) handover() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := s.runner.listenerFiles()
+	if err != nil {
+		return 0, err
+	}
+	if s.decoy != nil {
+		f, err := s.decoy.File()
+		if err != nil {
+			closeFiles(files)
+			return 0, err
+		}
+		files[decoyListenerKey(s.decoy.Addr())] = f
+	}
+	if s.metricsSrv != nil {
+		f, err := s.metricsSrv.File()
+		if err != nil {
+			closeFiles(files)
+			return 0, err
+		}
+		files[listenerKey("tcp", s.cfg.Metrics.Listen)] = f
+	}
+	if s.control != nil {
+		f, err := s.control.File()
+		if err != nil {
+			closeFiles(files)
+			return 0, err
+		}
+		files[controlListenerKey(s.cfg.Control.Listen)] = f
+	}
+	defer closeFiles(files)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer readyR.Close()
+
+	// ExtraFiles[i] becomes fd 3+i in the new process; the ready pipe comes first.
+	extra := []*os.File{readyW}
+	fds := make(map[string]int, len(files))
+	for key, f := range files {
+		fds[key] = 3 + len(extra)
+		extra = append(extra, f)
+	}
+	fdsJSON, err := json.Marshal(fds)
+	if err != nil {
+		readyW.Close()
+		return 0, err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = extra
+	cmd.Env = append(os.Environ(), envInheritedListeners+"="+string(fdsJSON), envUpgradeReadyFD+"=3")
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := readyR.Read(buf)
+		ready <- err
+	}()
+	select {
+	case err := <-ready:
+		if err != nil {
+			_ = cmd.Process.Kill()
+			return 0, fmt.Errorf("new process %d exited before it was ready", cmd.Process.Pid)
+		}
+	case err := <-exited:
+		return 0, fmt.Errorf("new process exited: %v", err)
+	case <-time.After(upgradeReadyTimeout):
+		_ = cmd.Process.Kill()
+		return 0, fmt.Errorf("new process %d not ready after %s", cmd.Process.Pid, upgradeReadyTimeout)
+	}
+
+	s.runner.stopAccepting(true)
+	if s.decoy != nil {
+		s.decoy.Detach()
+	}
+	if s.metricsSrv != nil {
+		_ = s.metricsSrv.Close() // the new process serves /metrics from now on
+	}
+	if s.control != nil {
+		s.control.Detach()
+	}
+	return cmd.Process.Pid, nil
+}
+
+func closeFiles(files map[string]*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// SignalUpgrade asks the running server recorded in the config's PID file to upgrade itself (sends SIGUSR2).
+func SignalUpgrade(cfg *models.ServerConfig) (int, error) {
+	path := pidFileOf(cfg)
+	pid, err := readPIDFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := syscall.Kill(pid, syscall.SIGUSR2); err != nil {
+		return 0, fmt.Errorf("signal pid %d (from %s): %v", pid, path, err)
+	}
+	return pid, nil
+}
@@ -0,0 +1,206 @@
+//go:build !windows
+
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : upgrade_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 07:02:15
+ * Description : Tests for the upgrade handover: a re-executed test binary inherits a gate listener without refusals.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+)
+
+// envUpgradeChild carries the gate address to the new process started by TestUpgradeHandover.
+const envUpgradeChild = "ABDAL_GOST_TEST_UPGRADE_CHILD"
+
+// versionStandIn stands in for Xray's internal listener and answers version on every connection.
+func versionStandIn(t *testing.T, version string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				if _, err := proxyproto.Read(r); err != nil {
+					return
+				}
+				_, _ = r.ReadString('\n')
+				_, _ = io.WriteString(c, version+"\n")
+			}(c)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// hello sends one line on c and returns the answer.
+func hello(c net.Conn) (string, error) {
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(c, "ping\n"); err != nil {
+		return "", err
+	}
+	answer, err := io.ReadAll(c)
+	return strings.TrimSpace(string(answer)), err
+}
+
+// TestUpgradeChild is the new process of TestUpgradeHandover: it serves the inherited gate listener until stdin closes.
+func TestUpgradeChild(t *testing.T) {
+	addr := os.Getenv(envUpgradeChild)
+	if addr == "" {
+		t.Skip("started by TestUpgradeHandover")
+	}
+	// Binding addr again would fail: the old process still listens on it.
+	g, err := newProxyProtocolGate(gatePlan{network: "tcp", listenAddr: addr, internalAddr: versionStandIn(t, "new")}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	notifyUpgradeReady()
+	_, _ = io.Copy(io.Discard, os.Stdin)
+}
+
+func TestUpgradeHandover(t *testing.T) {
+	if os.Getenv(envUpgradeChild) != "" {
+		t.Skip("running as the new process")
+	}
+	g, err := newProxyProtocolGate(gatePlan{network: "tcp", listenAddr: "127.0.0.1:0", internalAddr: versionStandIn(t, "old")}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	addr := g.raw.Addr().String()
+	// A connection accepted before the handover is served by the old process to the end.
+	inFlight, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inFlight.Close()
+	waitFor(t, 5*time.Second, func() bool { return g.Active() > 0 })
+
+	// Clients connect without pause through the whole handover.
+	var (
+		mu       sync.Mutex
+		answers  = map[string]int{}
+		failures []string
+	)
+	count := func(version string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return answers[version]
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			c, err := net.DialTimeout("tcp", addr, 5*time.Second)
+			answer := ""
+			if err == nil {
+				answer, err = hello(c)
+				c.Close()
+			}
+			mu.Lock()
+			if err != nil || answer == "" {
+				failures = append(failures, "dial: "+answer+" "+errString(err))
+			} else {
+				answers[answer]++
+			}
+			mu.Unlock()
+		}
+	}()
+	waitFor(t, 5*time.Second, func() bool { return count("old") > 0 })
+
+	// What handover does: pass the listener and a ready pipe to the new process, wait, then stop accepting.
+	f, err := g.File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readyR.Close()
+	fds, _ := json.Marshal(map[string]int{listenerKey("tcp", addr): 4})
+	cmd := exec.Command(os.Args[0], "-test.run=^TestUpgradeChild$")
+	cmd.ExtraFiles = []*os.File{readyW, f}
+	cmd.Env = append(os.Environ(), envInheritedListeners+"="+string(fds), envUpgradeReadyFD+"=3", envUpgradeChild+"="+addr)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	readyW.Close()
+	f.Close()
+	defer func() {
+		stdin.Close()
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("new process: %v", err)
+		}
+	}()
+	_ = readyR.SetReadDeadline(time.Now().Add(30 * time.Second))
+	if _, err := readyR.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("new process not ready: %v", err)
+	}
+	if err := g.Detach(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, 10*time.Second, func() bool { return count("new") >= 20 })
+	close(stop)
+	<-stopped
+	if answer, err := hello(inFlight); answer != "old" {
+		t.Errorf("in-flight connection answered %q (%v), want old", answer, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failures) > 0 {
+		t.Errorf("%d of %d connections failed during the handover, first: %s", len(failures), len(failures)+answers["old"]+answers["new"], failures[0])
+	}
+	t.Logf("answers: %v", answers)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "no answer"
+	}
+	return err.Error()
+}
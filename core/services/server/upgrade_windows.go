@@ -0,0 +1,40 @@
+//go:build windows
+
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : upgrade_windows.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 14:36:40
+ * Description : Zero-downtime binary upgrade is not available on Windows (no SIGUSR2 / fd inheritance).
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+// HandleUpgrades only reports that upgrades are unsupported when upgrade.enabled is set.
+func (s *Server) HandleUpgrades(ctx context.Context, done func()) {
+	if s.cfg.Upgrade.Enabled {
+		logger.Warnf("upgrade.enabled is ignored on Windows")
+	}
+}
+
+// SignalUpgrade is not supported on Windows.
+func SignalUpgrade(cfg *models.ServerConfig) (int, error) {
+	return 0, fmt.Errorf("zero-downtime upgrade is not supported on Windows")
+}
@@ -0,0 +1,194 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : webhooks.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 22:27:41
+ * Description : Server events sent to webhooks (users, connection limits, Reality dest health, reloads).
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/security"
+	"github.com/ebrasha/abdal-gost-proxy/core/webhook"
+)
+
+// Webhook event types.
+const (
+	eventUserAdded       = "user.added"
+	eventUserRemoved     = "user.removed"
+	eventConnectionLimit = "user.connection_limit"
+	eventQuotaReached    = "user.quota_reached"
+	eventUserExpired     = "user.expired"
+	eventDestUnreachable = "reality.dest_unreachable"
+	eventDestRecovered   = "reality.dest_recovered"
+	eventReloadSucceeded = "reload.succeeded"
+	eventReloadFailed    = "reload.failed"
+)
+
+// connectionLimitEvery allows at most one connection_limit event per user and minute.
+const connectionLimitEvery = time.Minute
+
+var webhookEvents = []string{
+	eventUserAdded, eventUserRemoved, eventConnectionLimit, eventQuotaReached, eventUserExpired,
+	eventDestUnreachable, eventDestRecovered, eventReloadSucceeded, eventReloadFailed,
+}
+
+// Reality dest probing (only while webhooks are configured).
+const (
+	destProbeInterval = time.Minute
+	destProbeTimeout  = 5 * time.Second
+)
+
+// checkWebhooks validates the webhook URLs and event names.
+func checkWebhooks(cfg *models.ServerConfig) error {
+	known := make(map[string]bool, len(webhookEvents))
+	for _, e := range webhookEvents {
+		known[e] = true
+	}
+	for i, w := range cfg.Webhooks {
+		if err := webhook.Check(w.URL); err != nil {
+			return fmt.Errorf("webhooks[%d]: %v", i, err)
+		}
+		if w.TimeoutSeconds < 0 {
+			return fmt.Errorf("webhooks[%d]: timeout_seconds must not be negative", i)
+		}
+		for _, e := range w.Events {
+			if !known[e] {
+				return fmt.Errorf("webhooks[%d]: unknown event %q", i, e)
+			}
+		}
+	}
+	return nil
+}
+
+// webhookOptions converts the webhook config for the dispatcher.
+func webhookOptions(cfg []models.WebhookConfig) []webhook.Options {
+	opts := make([]webhook.Options, 0, len(cfg))
+	for _, w := range cfg {
+		retries := -1 // default
+		if w.MaxRetries != nil {
+			retries = *w.MaxRetries
+		}
+		opts = append(opts, webhook.Options{
+			URL:        w.URL,
+			Secret:     w.Secret,
+			Events:     w.Events,
+			Timeout:    time.Duration(w.TimeoutSeconds) * time.Second,
+			MaxRetries: retries,
+		})
+	}
+	return opts
+}
+
+// notifyUserChanges sends user.added / user.removed for the difference between old and cfg.
+func (s *Server) notifyUserChanges(old, cfg *models.ServerConfig) {
+	before := make(map[string]bool, len(old.Users))
+	for _, u := range old.Users {
+		before[u.Email] = true
+	}
+	after := make(map[string]bool, len(cfg.Users))
+	for _, u := range cfg.Users {
+		after[u.Email] = true
+		if !before[u.Email] {
+			s.hooks.Notify(eventUserAdded, map[string]interface{}{"user": u.Email})
+		}
+	}
+	for _, u := range old.Users {
+		if !after[u.Email] {
+			s.hooks.Notify(eventUserRemoved, map[string]interface{}{"user": u.Email})
+		}
+	}
+}
+
+// realityDests returns the distinct Reality dest addresses of all inbounds.
+func realityDests(cfg *models.ServerConfig) []string {
+	x, err := buildXrayConfig(cfg)
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var dests []string
+	for _, in := range x.Inbounds {
+		if in.StreamSettings == nil || in.StreamSettings.RealitySettings == nil {
+			continue
+		}
+		if d := in.StreamSettings.RealitySettings.Dest; d != "" && !seen[d] {
+			seen[d] = true
+			dests = append(dests, d)
+		}
+	}
+	sort.Strings(dests)
+	return dests
+}
+
+// probeDest dials a Reality dest ("host:port", a bare port on loopback, or a unix socket path).
+func probeDest(ctx context.Context, dest string) error {
+	network, addr := "tcp", dest
+	if security.IsUnixSocketPath(dest) {
+		network = "unix"
+	} else if _, _, err := net.SplitHostPort(dest); err != nil {
+		addr = net.JoinHostPort("127.0.0.1", dest)
+	}
+	ctx, cancel := context.WithTimeout(ctx, destProbeTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probeDests checks the Reality dests every destProbeInterval while webhooks are configured
+// and reports changes in reachability.
+func (s *Server) probeDests(ctx context.Context) {
+	down := make(map[string]bool)
+	ticker := time.NewTicker(destProbeInterval)
+	defer ticker.Stop()
+	for {
+		if s.hooks.Enabled() {
+			s.mu.Lock()
+			cfg := s.cfg
+			s.mu.Unlock()
+			for _, dest := range realityDests(cfg) {
+				err := probeDest(ctx, dest)
+				if ctx.Err() != nil {
+					return
+				}
+				switch {
+				case err != nil && !down[dest]:
+					down[dest] = true
+					logger.Warnf("reality dest %s unreachable: %v", dest, err)
+					s.hooks.Notify(eventDestUnreachable, map[string]interface{}{"dest": dest, "error": err.Error()})
+				case err == nil && down[dest]:
+					delete(down, dest)
+					logger.Infof("reality dest %s reachable again", dest)
+					s.hooks.Notify(eventDestRecovered, map[string]interface{}{"dest": dest})
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -20,24 +20,42 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
 	"github.com/ebrasha/abdal-gost-proxy/core/models"
 	"github.com/ebrasha/abdal-gost-proxy/core/security"
 )
 
 // xrayInbound represents one inbound in Xray JSON format.
 type xrayInbound struct {
-	Listen   string          `json:"listen"`
-	Port     int             `json:"port"`
-	Protocol string          `json:"protocol"`
-	Settings *xrayVLESSSet    `json:"settings"`
-	StreamSettings *xrayStream `json:"streamSettings"`
-	Sniffing *xraySniffing   `json:"sniffing,omitempty"`
+	Listen         string        `json:"listen"`
+	Port           interface{}   `json:"port,omitempty"` // number, "from-to" string for port ranges, or nil for unix sockets
+	Protocol       string        `json:"protocol"`
+	Tag            string        `json:"tag,omitempty"`
+	Settings       interface{}   `json:"settings"` // *xrayVLESSSet, or *xrayDokodemo for reverse tunnel listeners
+	StreamSettings *xrayStream   `json:"streamSettings,omitempty"`
+	Sniffing       *xraySniffing `json:"sniffing,omitempty"`
+}
+
+// vless returns the VLESS settings of in (nil for reverse tunnel listeners).
+func (in *xrayInbound) vless() *xrayVLESSSet {
+	s, _ := in.Settings.(*xrayVLESSSet)
+	return s
 }
 
 type xrayVLESSSet struct {
-	Clients      []xrayClient `json:"clients"`
-	Decryption   string       `json:"decryption"`
+	Clients    []xrayClient   `json:"clients"`
+	Decryption string         `json:"decryption"`
+	Fallbacks  []xrayFallback `json:"fallbacks,omitempty"`
+}
+
+type xrayFallback struct {
+	Name string `json:"name,omitempty"`
+	Alpn string `json:"alpn,omitempty"`
+	Path string `json:"path,omitempty"`
+	Dest string `json:"dest"`
+	Xver int    `json:"xver,omitempty"`
 }
 
 type xrayClient struct {
@@ -47,10 +65,15 @@ type xrayClient struct {
 }
 
 type xrayStream struct {
-	Network   string            `json:"network"`
-	Security  string            `json:"security"`
+	Network         string       `json:"network"`
+	Security        string       `json:"security"`
 	RealitySettings *xrayReality `json:"realitySettings,omitempty"`
-	GRPCSettings   *xrayGRPC   `json:"grpcSettings,omitempty"`
+	GRPCSettings    *xrayGRPC    `json:"grpcSettings,omitempty"`
+	Sockopt         *xraySockopt `json:"sockopt,omitempty"`
+}
+
+type xraySockopt struct {
+	AcceptProxyProtocol bool `json:"acceptProxyProtocol,omitempty"`
 }
 
 type xrayReality struct {
@@ -70,33 +93,136 @@ type xrayGRPC struct {
 type xraySniffing struct {
 	Enabled      bool     `json:"enabled"`
 	DestOverride []string `json:"destOverride"`
+	RouteOnly    bool     `json:"routeOnly"`
 }
 
 type xrayOutbound struct {
-	Protocol string `json:"protocol"`
-	Tag      string `json:"tag"`
+	Protocol       string             `json:"protocol"`
+	Tag            string             `json:"tag"`
+	SendThrough    string             `json:"sendThrough,omitempty"`
+	Settings       interface{}        `json:"settings,omitempty"`
+	StreamSettings interface{}        `json:"streamSettings,omitempty"`
+	ProxySettings  *xrayProxySettings `json:"proxySettings,omitempty"`
+}
+
+// xrayProxySettings dials an outbound through another one (transportLayer = sockopt.dialerProxy).
+type xrayProxySettings struct {
+	Tag            string `json:"tag"`
+	TransportLayer bool   `json:"transportLayer"`
 }
 
 type xrayConfig struct {
 	Log       *xrayLog       `json:"log,omitempty"`
 	Inbounds  []xrayInbound  `json:"inbounds"`
 	Outbounds []xrayOutbound `json:"outbounds"`
+	Routing   *xrayRouting   `json:"routing"`
+	DNS       *xrayDNS       `json:"dns,omitempty"`
+}
+
+// xrayRouting is always emitted so the router exists and its rules can be hot-reloaded.
+type xrayRouting struct {
+	DomainStrategy string            `json:"domainStrategy"`
+	Rules          []json.RawMessage `json:"rules"`
 }
 
 type xrayLog struct {
 	Loglevel string `json:"loglevel"`
+	Access   string `json:"access,omitempty"` // "none" disables the access log
 }
 
-// BuildXrayJSON converts ServerConfig to Xray-compatible JSON (VLESS + gRPC + Reality).
+// BuildXrayJSON converts ServerConfig to Xray-compatible JSON (one VLESS + gRPC/TCP + Reality inbound per resolved inbound).
 func BuildXrayJSON(cfg *models.ServerConfig) ([]byte, error) {
+	xcfg, err := buildXrayConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(xcfg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// buildXrayConfig converts ServerConfig to the Xray config structure (used directly by hot reload diffs).
+func buildXrayConfig(cfg *models.ServerConfig) (*xrayConfig, error) {
+	resolved, err := resolveInbounds(cfg)
+	if err != nil {
+		return nil, err
+	}
+	inbounds := make([]xrayInbound, 0, len(resolved))
+	hasTCP := false
+	for i := range resolved {
+		in, err := buildInbound(&resolved[i])
+		if err != nil {
+			return nil, fmt.Errorf("inbound %s: %v", resolved[i].tag, err)
+		}
+		if isRawTCP(in.StreamSettings.Network) {
+			hasTCP = true
+		}
+		inbounds = append(inbounds, in)
+	}
+	if cfg.Decoy.Enabled && !hasTCP {
+		return nil, fmt.Errorf("decoy requires at least one inbound with transport.type \"tcp\"")
+	}
+
+	outbounds, err := buildOutbounds(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policyRules, policyOutbounds, err := buildPolicyRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	egressRules, err := buildEgressRules(cfg, outbounds)
+	if err != nil {
+		return nil, err
+	}
+	outbounds = append(outbounds, policyOutbounds...)
+	reverseInbounds, reverseRules, err := buildReverse(cfg, inbounds, outbounds)
+	if err != nil {
+		return nil, err
+	}
+	inbounds = append(inbounds, reverseInbounds...)
+	dns, dnsDispatched, err := buildDNS(&cfg.DNS)
+	if err != nil {
+		return nil, err
+	}
+	var dnsRules []xrayRule
+	if dnsDispatched {
+		dnsRules = append(dnsRules, xrayRule{Type: "field", InboundTag: []string{dnsInboundTag}, OutboundTag: outboundDirect})
+	}
+	rules, err := marshalRules(append(append(append(reverseRules, dnsRules...), policyRules...), egressRules...))
+	if err != nil {
+		return nil, err
+	}
+
+	logLevel, access := logger.XrayLogConfig(&cfg.Log)
+	if cfg.AccessLog.Enabled {
+		access = "none" // the access log replaces Xray's lines, which would bypass its privacy modes
+	}
+	return &xrayConfig{
+		Log:       &xrayLog{Loglevel: logLevel, Access: access},
+		Inbounds:  inbounds,
+		Outbounds: outbounds,
+		Routing:   &xrayRouting{DomainStrategy: "AsIs", Rules: rules},
+		DNS:       dns,
+	}, nil
+}
+
+// buildInbound converts one resolved inbound to Xray inbound JSON.
+func buildInbound(in *resolvedInbound) (xrayInbound, error) {
+	cfg := &in.cfg
 	realityParams := security.FromServerReality(&cfg.RealitySettings)
 	defaultHost := cfg.Fallback.DefaultHost
 	if defaultHost == "" {
 		defaultHost = "www.samsung.com"
 	}
-	fallbackDest := security.ResolveFallbackDest(cfg.Fallback.Dest, defaultHost)
-	if realityParams.Dest != "" {
-		fallbackDest = realityParams.Dest
+	// Reality dest only borrows the legacy fallback when reality_settings.dest is unset.
+	realityDest := realityParams.Dest
+	if realityDest == "" {
+		realityDest = security.ResolveFallbackDest(cfg.Fallback.Dest, defaultHost)
 	}
 
 	protocol := cfg.Protocol
@@ -118,6 +244,11 @@ func BuildXrayJSON(cfg *models.ServerConfig) ([]byte, error) {
 		clients = append(clients, xrayClient{ID: u.ID, Email: u.Email, Flow: flow})
 	}
 
+	fallbacks, err := buildFallbacks(cfg, network)
+	if err != nil {
+		return xrayInbound{}, err
+	}
+
 	serviceName := cfg.Transport.ServiceName
 	if serviceName == "" {
 		serviceName = "abdal-grpc-stream"
@@ -128,7 +259,7 @@ func BuildXrayJSON(cfg *models.ServerConfig) ([]byte, error) {
 		Security: "reality",
 		RealitySettings: &xrayReality{
 			Show:        false,
-			Dest:        fallbackDest,
+			Dest:        realityDest,
 			Xver:        cfg.Fallback.XVer,
 			ServerNames: realityParams.ServerNames,
 			PrivateKey:  realityParams.PrivateKey,
@@ -136,6 +267,9 @@ func BuildXrayJSON(cfg *models.ServerConfig) ([]byte, error) {
 		},
 		GRPCSettings: nil,
 	}
+	if cfg.ProxyProtocol.Enabled {
+		streamSettings.Sockopt = &xraySockopt{AcceptProxyProtocol: true}
+	}
 	if network == "grpc" {
 		streamSettings.GRPCSettings = &xrayGRPC{
 			ServiceName: serviceName,
@@ -143,33 +277,56 @@ func BuildXrayJSON(cfg *models.ServerConfig) ([]byte, error) {
 		}
 	}
 
-	xcfg := xrayConfig{
-		Log: &xrayLog{Loglevel: "warning"},
-		Inbounds: []xrayInbound{{
-			Listen:   cfg.ListenAddress,
-			Port:     cfg.ListenPort,
-			Protocol: protocol,
-			Settings: &xrayVLESSSet{
-				Clients:    clients,
-				Decryption: "none",
-			},
-			StreamSettings: streamSettings,
-			Sniffing: &xraySniffing{
-				Enabled:      true,
-				DestOverride: []string{"http", "tls", "quic"},
-			},
-		}},
-		Outbounds: []xrayOutbound{
-			{Protocol: "freedom", Tag: "direct"},
-			{Protocol: "blackhole", Tag: "block"},
-		},
+	listen, err := in.listen()
+	if err != nil {
+		return xrayInbound{}, err
 	}
+	return xrayInbound{
+		Listen:   listen,
+		Port:     in.port(),
+		Protocol: protocol,
+		Tag:      in.tag,
+		Settings: &xrayVLESSSet{
+			Clients:    clients,
+			Decryption: "none",
+			Fallbacks:  fallbacks,
+		},
+		StreamSettings: streamSettings,
+		Sniffing: &xraySniffing{
+			Enabled:      true,
+			DestOverride: []string{"http", "tls", "quic"},
+			// Route on the sniffed domain but dial the requested address, so a Reality handshake
+			// tunnelled through this server (a via chain) is not sent to its cover SNI.
+			RouteOnly: true,
+		},
+	}, nil
+}
 
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
-	enc.SetEscapeHTML(false)
-	if err := enc.Encode(xcfg); err != nil {
+// buildFallbacks returns VLESS fallback rules; Xray only applies them on raw TCP transport.
+// When the decoy site is enabled it replaces the legacy fallback as catch-all.
+func buildFallbacks(cfg *models.ServerConfig, network string) ([]xrayFallback, error) {
+	if !isRawTCP(network) {
+		if len(cfg.Fallbacks) > 0 {
+			return nil, fmt.Errorf("fallbacks require transport.type \"tcp\" (got %q)", network)
+		}
+		return nil, nil
+	}
+	catchAll := &cfg.Fallback
+	if cfg.Decoy.Enabled {
+		catchAll = &models.FallbackConfig{Dest: decoyListenAddr(&cfg.Decoy)}
+	}
+	params, err := security.FromServerFallbacks(cfg.Fallbacks, catchAll)
+	if err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	out := make([]xrayFallback, 0, len(params))
+	for _, p := range params {
+		out = append(out, xrayFallback{Name: p.Name, Alpn: p.Alpn, Path: p.Path, Dest: p.Dest, Xver: p.XVer})
+	}
+	return out, nil
+}
+
+// isRawTCP reports whether network is Xray's raw TCP transport (the only one with VLESS fallbacks).
+func isRawTCP(network string) bool {
+	return network == "tcp" || network == "raw"
 }
@@ -20,49 +20,259 @@ package server
 import (
 	"bytes"
 	"context"
+	"os"
+	"sync"
+	"time"
 
+	"github.com/ebrasha/abdal-gost-proxy/core/conntrack"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
 	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	"github.com/ebrasha/abdal-gost-proxy/core/netutil"
+	"github.com/xtls/xray-core/app/reverse"
 	"github.com/xtls/xray-core/core"
-	_ "github.com/xtls/xray-core/main/distro/all"
 	"github.com/xtls/xray-core/infra/conf/serial"
+	_ "github.com/xtls/xray-core/main/distro/all"
 )
 
 // XrayRunner holds Xray instance and config for lifecycle management.
 type XrayRunner struct {
-	instance *core.Instance
-	config   *models.ServerConfig
+	instance  *core.Instance
+	config    *models.ServerConfig
+	gatePlans []gatePlan
+	gates     map[string][]*ProxyProtocolGate // by inbound tag
+	tracker   *conntrack.Tracker
+	relays    []*dotRelay                // DNS-over-TLS relays of the dns section (not reloaded)
+	portals   map[string]*reverse.Portal // by reverse tunnel name
+	onReject  func(reason string)        // connections refused by a gate
+	drainer   netutil.Drainer
+	mu        sync.Mutex
 }
 
 // NewXrayRunner builds Xray config from ServerConfig and creates runner (does not start).
+// Inbounds with PROXY protocol trusted sources (all inbounds when upgrade or drain is on) listen on internal sockets behind a ProxyProtocolGate.
 func NewXrayRunner(cfg *models.ServerConfig) (*XrayRunner, error) {
-	jsonBytes, err := BuildXrayJSON(cfg)
+	xrayCfg, plans, err := planGates(cfg)
 	if err != nil {
 		return nil, err
 	}
-	xrayConfig, err := serial.LoadJSONConfig(bytes.NewReader(jsonBytes))
+	relays, err := startDoTRelays(xrayCfg)
 	if err != nil {
 		return nil, err
 	}
-	instance, err := core.New(xrayConfig)
+	instance, xrayConfig, err := newXrayInstance(xrayCfg)
 	if err != nil {
+		closeDoTRelays(relays)
+		return nil, err
+	}
+	tracker := conntrack.NewTracker()
+	for _, ob := range xrayConfig.Outbound {
+		if err := tracker.Wrap(instance, ob.Tag); err != nil {
+			_ = instance.Close()
+			closeDoTRelays(relays)
+			return nil, err
+		}
+	}
+	r := &XrayRunner{instance: instance, config: cfg, gatePlans: plans, gates: make(map[string][]*ProxyProtocolGate), tracker: tracker, relays: relays}
+	if _, err := r.startPortals(cfg); err != nil {
+		_ = r.Close()
 		return nil, err
 	}
-	return &XrayRunner{instance: instance, config: cfg}, nil
+	return r, nil
+}
+
+// newXrayInstance builds and loads the Xray config of cfg.
+func newXrayInstance(cfg *models.ServerConfig) (*core.Instance, *core.Config, error) {
+	jsonBytes, err := BuildXrayJSON(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	xrayConfig, err := serial.LoadJSONConfig(bytes.NewReader(jsonBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+	instance, err := core.New(xrayConfig)
+	return instance, xrayConfig, err
 }
 
 // Start starts the Xray instance (blocking until context is cancelled).
+// With drain.timeout_seconds set, open connections may finish before the instance is closed.
 func (r *XrayRunner) Start(ctx context.Context) error {
-	if err := r.instance.Start(); err != nil {
+	if err := r.start(); err != nil {
+		_ = r.Close()
 		return err
 	}
 	<-ctx.Done()
-	return r.instance.Close()
+	r.mu.Lock()
+	timeout := time.Duration(r.config.Drain.TimeoutSeconds) * time.Second
+	r.mu.Unlock()
+	if timeout > 0 {
+		r.stopAccepting(false)
+		if r.activeConns() > 0 {
+			forced := r.drain(timeout, printDrainProgress)
+			logger.Infof("drain finished (%d connections force-closed)", forced)
+		}
+	}
+	return r.Close()
+}
+
+// printDrainProgress prints one drain progress line.
+func printDrainProgress(st netutil.DrainStatus) {
+	logger.Infof("draining: %d connections open, force-close in %s", st.Active, time.Until(st.Deadline).Round(time.Second))
 }
 
-// Close stops the Xray instance.
+func (r *XrayRunner) start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.instance.Start(); err != nil {
+		return err
+	}
+	if err := r.chownSockets(r.config, nil); err != nil {
+		return err
+	}
+	if err := r.startGates(r.gatePlans, nil); err != nil {
+		return err
+	}
+	closeUnusedInherited()
+	notifyUpgradeReady()
+	return nil
+}
+
+// Tracker returns the tracker of every connection dispatched to an outbound.
+func (r *XrayRunner) Tracker() *conntrack.Tracker {
+	return r.tracker
+}
+
+// startGates starts the planned gates, limited to tags when tags is non-nil.
+func (r *XrayRunner) startGates(plans []gatePlan, tags map[string]bool) error {
+	for _, p := range plans {
+		if tags != nil && !tags[p.tag] {
+			continue
+		}
+		gate, err := newProxyProtocolGate(p, r.instance, r.onReject)
+		if err != nil {
+			return err
+		}
+		r.gates[p.tag] = append(r.gates[p.tag], gate)
+	}
+	return nil
+}
+
+// closeGates stops the gates of one inbound.
+func (r *XrayRunner) closeGates(tag string) {
+	for _, g := range r.gates[tag] {
+		_ = g.Close()
+	}
+	delete(r.gates, tag)
+}
+
+// chownSockets applies unix_socket.owner to inbound sockets created by Xray, limited to tags when tags is non-nil.
+func (r *XrayRunner) chownSockets(cfg *models.ServerConfig, tags map[string]bool) error {
+	resolved, err := resolveInbounds(cfg)
+	if err != nil {
+		return err
+	}
+	for i := range resolved {
+		in := &resolved[i]
+		if !in.isUnix() || (tags != nil && !tags[in.tag]) {
+			continue
+		}
+		if err := netutil.ChownSocket(in.cfg.ListenAddress, in.cfg.UnixSocket.Owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listenerFiles duplicates every gate socket for the new process, keyed by listenerKey.
+func (r *XrayRunner) listenerFiles() (map[string]*os.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	files := make(map[string]*os.File)
+	for _, gates := range r.gates {
+		for _, g := range gates {
+			f, err := g.File()
+			if err != nil {
+				for _, f := range files {
+					_ = f.Close()
+				}
+				return nil, err
+			}
+			files[g.key()] = f
+		}
+	}
+	return files, nil
+}
+
+// stopAccepting closes every gate listener; relayed connections keep running.
+// detach keeps unix socket files because a new process serves them after an upgrade.
+func (r *XrayRunner) stopAccepting(detach bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, gates := range r.gates {
+		for _, g := range gates {
+			if detach {
+				_ = g.Detach()
+			} else {
+				_ = g.Close()
+			}
+		}
+	}
+}
+
+// activeConns returns the number of connections relayed by the gates.
+func (r *XrayRunner) activeConns() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, gates := range r.gates {
+		for _, g := range gates {
+			n += g.Active()
+		}
+	}
+	return n
+}
+
+// forceCloseConns closes every relayed connection and returns how many were open.
+func (r *XrayRunner) forceCloseConns() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, gates := range r.gates {
+		for _, g := range gates {
+			n += g.CloseConns()
+		}
+	}
+	return n
+}
+
+// drain waits up to timeout for gate connections to finish, then force-closes the rest and returns their count.
+func (r *XrayRunner) drain(timeout time.Duration, report func(netutil.DrainStatus)) int {
+	return r.drainer.Run(timeout, r.activeConns, r.forceCloseConns, report)
+}
+
+// DrainStatus returns the progress of the current (or last) drain.
+func (r *XrayRunner) DrainStatus() netutil.DrainStatus {
+	return r.drainer.Status()
+}
+
+// Close stops the gates (if any) with their connections and the Xray instance.
 func (r *XrayRunner) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for tag, gates := range r.gates {
+		for _, g := range gates {
+			_ = g.CloseConns()
+		}
+		r.closeGates(tag)
+	}
+	closeDoTRelays(r.relays)
+	r.relays = nil
 	if r.instance == nil {
 		return nil
 	}
-	return r.instance.Close()
+	err := r.instance.Close()
+	r.instance = nil
+	r.portals = nil
+	removeInternalSockets()
+	return err
 }
@@ -0,0 +1,320 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : store.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 22:58:03
+ * Description : Embedded on-disk user database (users, quotas, expiry, traffic counters, notes) on bbolt.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package userstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// openTimeout is how long Open waits for another process (server or CLI) to release the file.
+const openTimeout = 10 * time.Second
+
+var usersBucket = []byte("users")
+
+// User is one stored user: the fields of a config users[] entry plus traffic counters.
+// Its JSON form is a superset of models.ServerUser, so exports can be pasted into users[] and back.
+type User struct {
+	models.ServerUser
+	UpBytes   int64     `json:"up_bytes,omitempty"`
+	DownBytes int64     `json:"down_bytes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store is an open user database. Every change is one atomic transaction.
+// The file is locked while open, so callers keep it open only for the duration of an operation.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (creating if needed) the database at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("user store %s: %v", path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(usersBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("user store %s: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// With opens the database at path, runs fn and closes it again.
+func With(path string, fn func(*Store) error) error {
+	st, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	return fn(st)
+}
+
+// Close releases the database file.
+func (st *Store) Close() error {
+	return st.db.Close()
+}
+
+// Check validates the fields shared with config users.
+func Check(u *models.ServerUser) error {
+	if u.Email == "" {
+		return fmt.Errorf("user without email")
+	}
+	if u.ID == "" {
+		return fmt.Errorf("user %s: id is required", u.Email)
+	}
+	if _, err := ParseExpiry(u.ExpiresAt); err != nil {
+		return fmt.Errorf("user %s: %v", u.Email, err)
+	}
+	if u.QuotaGB < 0 {
+		return fmt.Errorf("user %s: quota_gb must not be negative", u.Email)
+	}
+	return nil
+}
+
+// ParseExpiry parses expires_at: RFC 3339, or "YYYY-MM-DD" meaning the end of that day in local time.
+// Empty returns the zero time (never expires).
+func ParseExpiry(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+	return time.Time{}, fmt.Errorf("expires_at %q: must be RFC 3339 or YYYY-MM-DD", s)
+}
+
+// List returns all users sorted by email.
+func (st *Store) List() ([]User, error) {
+	var users []User
+	err := st.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+			var u User
+			if err := json.Unmarshal(v, &u); err != nil {
+				return fmt.Errorf("user %s: %v", k, err)
+			}
+			users = append(users, u)
+			return nil
+		})
+	})
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+	return users, err
+}
+
+// Get returns the user with email; ok is false when there is none.
+func (st *Store) Get(email string) (u User, ok bool, err error) {
+	err = st.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(usersBucket).Get([]byte(email))
+		if v == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(v, &u)
+	})
+	return u, ok, err
+}
+
+// Add stores a new user; it fails when the email or the id is already taken (Put replaces users).
+func (st *Store) Add(u User) error {
+	if err := Check(&u.ServerUser); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	u.CreatedAt, u.UpdatedAt = now, now
+	return st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		if b.Get([]byte(u.Email)) != nil {
+			return fmt.Errorf("user %s already exists", u.Email)
+		}
+		if err := checkStoredIDs(b, []User{u}); err != nil {
+			return err
+		}
+		return putUser(b, &u)
+	})
+}
+
+// Put adds or replaces users (by email) in one transaction and returns how many were added and updated.
+// Existing users keep created_at, and their counters unless the new entry carries counters (e.g. an export);
+// new users keep the created_at of an export.
+func (st *Store) Put(users []User) (added, updated int, err error) {
+	seenID := make(map[string]string)
+	for i := range users {
+		if err := Check(&users[i].ServerUser); err != nil {
+			return 0, 0, err
+		}
+		if other, dup := seenID[users[i].ID]; dup && other != users[i].Email {
+			return 0, 0, fmt.Errorf("users %s and %s share id %s", other, users[i].Email, users[i].ID)
+		}
+		seenID[users[i].ID] = users[i].Email
+	}
+	now := time.Now().UTC()
+	err = st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		if err := checkStoredIDs(b, users); err != nil {
+			return err
+		}
+		for _, u := range users {
+			if u.CreatedAt.IsZero() {
+				u.CreatedAt = now
+			}
+			u.UpdatedAt = now
+			if v := b.Get([]byte(u.Email)); v != nil {
+				var old User
+				if err := json.Unmarshal(v, &old); err == nil {
+					if !old.CreatedAt.IsZero() {
+						u.CreatedAt = old.CreatedAt
+					}
+					if u.UpBytes == 0 && u.DownBytes == 0 {
+						u.UpBytes, u.DownBytes = old.UpBytes, old.DownBytes
+					}
+				}
+				updated++
+			} else {
+				added++
+			}
+			if err := putUser(b, &u); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return added, updated, nil
+}
+
+// checkStoredIDs fails when one of users would share its id with another stored user that users do not replace.
+func checkStoredIDs(b *bolt.Bucket, users []User) error {
+	replaced := make(map[string]bool, len(users))
+	for _, u := range users {
+		replaced[u.Email] = true
+	}
+	return b.ForEach(func(k, v []byte) error {
+		if replaced[string(k)] {
+			return nil
+		}
+		var stored User
+		if err := json.Unmarshal(v, &stored); err != nil {
+			return fmt.Errorf("user %s: %v", k, err)
+		}
+		for _, u := range users {
+			if u.ID == stored.ID {
+				return fmt.Errorf("user %s: id %s already belongs to %s", u.Email, u.ID, stored.Email)
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes the user with email; ok is false when there was none.
+func (st *Store) Delete(email string) (ok bool, err error) {
+	err = st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		if b.Get([]byte(email)) == nil {
+			return nil
+		}
+		ok = true
+		return b.Delete([]byte(email))
+	})
+	return ok, err
+}
+
+// Traffic is a per-user byte count delta.
+type Traffic struct {
+	Up, Down int64
+}
+
+// AddTraffic adds deltas to the counters of the users that exist (others are ignored).
+func (st *Store) AddTraffic(deltas map[string]Traffic) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	return st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		for email, d := range deltas {
+			v := b.Get([]byte(email))
+			if v == nil {
+				continue
+			}
+			var u User
+			if err := json.Unmarshal(v, &u); err != nil {
+				return fmt.Errorf("user %s: %v", email, err)
+			}
+			u.UpBytes += d.Up
+			u.DownBytes += d.Down
+			if err := putUser(b, &u); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ResetTraffic sets the counters of email to zero; ok is false when there is no such user.
+func (st *Store) ResetTraffic(email string) (ok bool, err error) {
+	err = st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		v := b.Get([]byte(email))
+		if v == nil {
+			return nil
+		}
+		ok = true
+		var u User
+		if err := json.Unmarshal(v, &u); err != nil {
+			return err
+		}
+		u.UpBytes, u.DownBytes = 0, 0
+		u.UpdatedAt = time.Now().UTC()
+		return putUser(b, &u)
+	})
+	return ok, err
+}
+
+func putUser(b *bolt.Bucket, u *User) error {
+	v, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(u.Email), v)
+}
+
+// ParseUsers reads a users JSON array (a config users[] section or an export).
+func ParseUsers(data []byte) ([]User, error) {
+	var users []User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("users JSON: %v", err)
+	}
+	for i := range users {
+		users[i].Email = strings.TrimSpace(users[i].Email)
+	}
+	return users, nil
+}
@@ -0,0 +1,226 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : store_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 08:31:14
+ * Description : Tests for the user store: add, put, delete, id conflicts, counters and import/export round-trips.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package userstore
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/models"
+)
+
+const (
+	idA = "e3e96803-cb62-4bf0-8c5e-71cd02628430"
+	idB = "cd948868-b033-4ab7-85f7-eae730c69c88"
+	idC = "5b0b6f1c-8a41-4c4e-9d8e-3f0c2b7a9e11"
+)
+
+func openTemp(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.db")
+	st, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st, path
+}
+
+func user(email, id string) User {
+	return User{ServerUser: models.ServerUser{Email: email, ID: id}}
+}
+
+func mustGet(t *testing.T, st *Store, email string) User {
+	t.Helper()
+	u, ok, err := st.Get(email)
+	if err != nil || !ok {
+		t.Fatalf("get %s: %v, %v", email, ok, err)
+	}
+	return u
+}
+
+func TestAdd(t *testing.T) {
+	st, _ := openTemp(t)
+	a := user("a@x", idA)
+	a.QuotaGB, a.Notes, a.Group = 10, "paid until june", "vip"
+	if err := st.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Add(user("a@x", idB)); err == nil {
+		t.Error("adding an existing email succeeded")
+	}
+	if got := mustGet(t, st, "a@x"); got.ID != idA || got.QuotaGB != 10 || got.Notes != "paid until june" || got.Group != "vip" {
+		t.Errorf("existing user changed by a failed add: %+v", got)
+	}
+	if err := st.Add(user("b@x", idA)); err == nil {
+		t.Error("adding a user with another user's id succeeded")
+	}
+	if err := st.Add(user("", idC)); err == nil {
+		t.Error("adding a user without email succeeded")
+	}
+}
+
+func TestPut(t *testing.T) {
+	st, _ := openTemp(t)
+	added, updated, err := st.Put([]User{user("a@x", idA), user("b@x", idB)})
+	if err != nil || added != 2 || updated != 0 {
+		t.Fatalf("put = %d added, %d updated, %v", added, updated, err)
+	}
+	if err := st.AddTraffic(map[string]Traffic{"a@x": {Up: 100, Down: 200}}); err != nil {
+		t.Fatal(err)
+	}
+	created := mustGet(t, st, "a@x").CreatedAt
+
+	// Replacing keeps created_at and the counters.
+	a := user("a@x", idA)
+	a.QuotaGB = 5
+	if added, updated, err := st.Put([]User{a}); err != nil || added != 0 || updated != 1 {
+		t.Fatalf("replace = %d added, %d updated, %v", added, updated, err)
+	}
+	got := mustGet(t, st, "a@x")
+	if got.QuotaGB != 5 || got.UpBytes != 100 || got.DownBytes != 200 || !got.CreatedAt.Equal(created) {
+		t.Errorf("replaced user %+v", got)
+	}
+
+	// ids are unique within the batch and against the other stored users.
+	if _, _, err := st.Put([]User{user("c@x", idC), user("d@x", idC)}); err == nil {
+		t.Error("batch sharing an id accepted")
+	}
+	if _, _, err := st.Put([]User{user("c@x", idB)}); err == nil {
+		t.Error("id of b@x given to c@x")
+	}
+	if _, ok, _ := st.Get("c@x"); ok {
+		t.Error("rejected batch was partly stored")
+	}
+	// Swapping ids in one batch leaves them unique.
+	if _, _, err := st.Put([]User{user("a@x", idB), user("b@x", idA)}); err != nil {
+		t.Errorf("swapping ids: %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	st, _ := openTemp(t)
+	if _, _, err := st.Put([]User{user("a@x", idA), user("b@x", idB)}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := st.Delete("a@x"); !ok || err != nil {
+		t.Fatalf("delete = %v, %v", ok, err)
+	}
+	if ok, err := st.Delete("a@x"); ok || err != nil {
+		t.Errorf("second delete = %v, %v", ok, err)
+	}
+	users, err := st.List()
+	if err != nil || len(users) != 1 || users[0].Email != "b@x" {
+		t.Errorf("left %+v, %v", users, err)
+	}
+	// The id of a deleted user is free again.
+	if err := st.Add(user("c@x", idA)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCountersPersist(t *testing.T) {
+	st, path := openTemp(t)
+	if err := st.Add(user("a@x", idA)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := st.AddTraffic(map[string]Traffic{"a@x": {Up: 10, Down: 20}, "gone@x": {Up: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st.Close()
+
+	err := With(path, func(st *Store) error {
+		if got := mustGet(t, st, "a@x"); got.UpBytes != 30 || got.DownBytes != 60 {
+			t.Errorf("counters after reopening: %d/%d, want 30/60", got.UpBytes, got.DownBytes)
+		}
+		if _, ok, _ := st.Get("gone@x"); ok {
+			t.Error("traffic of an unknown user created it")
+		}
+		if ok, err := st.ResetTraffic("a@x"); !ok || err != nil {
+			t.Fatalf("reset = %v, %v", ok, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = With(path, func(st *Store) error {
+		if got := mustGet(t, st, "a@x"); got.UpBytes != 0 || got.DownBytes != 0 {
+			t.Errorf("counters after reset: %d/%d", got.UpBytes, got.DownBytes)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src, _ := openTemp(t)
+	a, b := user("a@x", idA), user("b@x", idB)
+	a.QuotaGB, a.ExpiresAt, a.Notes, a.Outbound = 2.5, "2027-01-31", "team", "relay"
+	if _, _, err := src.Put([]User{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AddTraffic(map[string]Traffic{"a@x": {Up: 1 << 30, Down: 7}}); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := src.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := ParseUsers(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, _ := openTemp(t)
+	if added, _, err := dst.Put(users); err != nil || added != 2 {
+		t.Fatalf("import = %d added, %v", added, err)
+	}
+	imported, err := dst.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range imported {
+		if !imported[i].CreatedAt.Equal(exported[i].CreatedAt) {
+			t.Errorf("%s: created_at %v, want %v", imported[i].Email, imported[i].CreatedAt, exported[i].CreatedAt)
+		}
+		imported[i].CreatedAt, imported[i].UpdatedAt = exported[i].CreatedAt, exported[i].UpdatedAt
+	}
+	if !reflect.DeepEqual(imported, exported) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", imported, exported)
+	}
+
+	// A plain config users[] section imports too.
+	cfgUsers, err := ParseUsers([]byte(`[{"id": "` + idC + `", "email": " c@x "}]`))
+	if err != nil || len(cfgUsers) != 1 || cfgUsers[0].Email != "c@x" {
+		t.Fatalf("config users: %+v, %v", cfgUsers, err)
+	}
+	if _, err := ParseUsers([]byte(`{"users": []}`)); err == nil {
+		t.Error("non-array JSON accepted")
+	}
+}
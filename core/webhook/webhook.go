@@ -0,0 +1,327 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : webhook.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-19 22:02:15
+ * Description : JSON webhook notifications with HMAC-SHA256 signatures and retry with backoff.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
+)
+
+// Request headers.
+const (
+	HeaderEvent     = "X-Abdal-Event"
+	HeaderDelivery  = "X-Abdal-Delivery"
+	HeaderSignature = "X-Abdal-Signature" // "sha256=" + hex HMAC-SHA256 of the body with the secret
+)
+
+const (
+	queueSize    = 256
+	maxBackoff   = time.Minute
+	flushTimeout = 5 * time.Second
+)
+
+// firstBackoff is the wait before the first retry; it doubles up to maxBackoff (a variable for tests).
+var firstBackoff = time.Second
+
+// Options configures one webhook endpoint.
+type Options struct {
+	URL        string
+	Secret     string        // HMAC key; empty = unsigned
+	Events     []string      // event types to send; empty = all
+	Timeout    time.Duration // per attempt, default 10s
+	MaxRetries int           // retries after the first attempt; negative = default 5, 0 = none
+}
+
+// Event is the JSON body of a webhook request.
+type Event struct {
+	ID   string                 `json:"id"`
+	Type string                 `json:"event"`
+	Time time.Time              `json:"time"`
+	Host string                 `json:"host"`
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Check validates the URL of a webhook.
+func Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("webhook url %q: %v", rawURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook url %q: must be an http:// or https:// URL", rawURL)
+	}
+	return nil
+}
+
+// Dispatcher sends events to every configured endpoint; it can be reconfigured while running.
+type Dispatcher struct {
+	senders []*sender
+	last    map[string]time.Time // NotifyEvery keys
+	host    string
+	mu      sync.Mutex
+}
+
+// NewDispatcher returns a dispatcher without endpoints.
+func NewDispatcher() *Dispatcher {
+	host, _ := os.Hostname()
+	return &Dispatcher{last: make(map[string]time.Time), host: host}
+}
+
+// Apply replaces the endpoints; the previous ones get a short time to deliver what is queued.
+func (d *Dispatcher) Apply(opts []Options) error {
+	for _, o := range opts {
+		if err := Check(o.URL); err != nil {
+			return err
+		}
+	}
+	senders := make([]*sender, 0, len(opts))
+	for _, o := range opts {
+		senders = append(senders, newSender(o))
+	}
+	d.mu.Lock()
+	old := d.senders
+	d.senders = senders
+	d.mu.Unlock()
+	closeSenders(old)
+	return nil
+}
+
+// Enabled reports whether any endpoint is configured.
+func (d *Dispatcher) Enabled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.senders) > 0
+}
+
+// Notify queues event for every endpoint that subscribes to it; it never blocks.
+func (d *Dispatcher) Notify(event string, data map[string]interface{}) {
+	d.mu.Lock()
+	senders := d.senders
+	d.mu.Unlock()
+	if len(senders) == 0 {
+		return
+	}
+	ev := Event{ID: newID(), Type: event, Time: time.Now().UTC(), Host: d.host, Data: data}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		logger.Errorf("webhook: %s: %v", event, err)
+		return
+	}
+	for _, s := range senders {
+		s.enqueue(ev, body)
+	}
+}
+
+// NotifyEvery is Notify, but sends at most one event per key within every (e.g. one per user and minute).
+func (d *Dispatcher) NotifyEvery(key string, every time.Duration, event string, data map[string]interface{}) {
+	now := time.Now()
+	d.mu.Lock()
+	if len(d.senders) == 0 || now.Sub(d.last[key]) < every {
+		d.mu.Unlock()
+		return
+	}
+	for k, t := range d.last {
+		if now.Sub(t) >= every {
+			delete(d.last, k)
+		}
+	}
+	d.last[key] = now
+	d.mu.Unlock()
+	d.Notify(event, data)
+}
+
+// Close delivers what is queued (waiting at most a few seconds) and stops all endpoints.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	old := d.senders
+	d.senders = nil
+	d.mu.Unlock()
+	closeSenders(old)
+}
+
+func closeSenders(senders []*sender) {
+	var wg sync.WaitGroup
+	for _, s := range senders {
+		wg.Add(1)
+		go func(s *sender) {
+			defer wg.Done()
+			s.close()
+		}(s)
+	}
+	wg.Wait()
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// delivery is one queued event with its encoded body (identical across retries, so signatures match).
+type delivery struct {
+	ev   Event
+	body []byte
+}
+
+// sender delivers the events of one endpoint in order.
+type sender struct {
+	opts   Options
+	events map[string]bool // nil = all
+	client *http.Client
+	queue  chan delivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	closed bool
+	mu     sync.Mutex
+}
+
+func newSender(o Options) *sender {
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 5
+	}
+	s := &sender{opts: o, client: &http.Client{Timeout: o.Timeout}, queue: make(chan delivery, queueSize), done: make(chan struct{})}
+	if len(o.Events) > 0 {
+		s.events = make(map[string]bool, len(o.Events))
+		for _, e := range o.Events {
+			s.events[e] = true
+		}
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run()
+	return s
+}
+
+func (s *sender) enqueue(ev Event, body []byte) {
+	if s.events != nil && !s.events[ev.Type] {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- delivery{ev: ev, body: body}:
+	default:
+		logger.Warnf("webhook %s: queue full, dropped %s event", s.opts.URL, ev.Type)
+	}
+}
+
+// close stops accepting events, waits up to flushTimeout for the queue, then abandons the rest.
+func (s *sender) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-time.After(flushTimeout):
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+}
+
+func (s *sender) run() {
+	defer close(s.done)
+	for d := range s.queue {
+		if s.ctx.Err() != nil {
+			continue
+		}
+		s.deliver(d)
+	}
+}
+
+// deliver posts d, retrying network errors, 429 and 5xx with exponential backoff.
+func (s *sender) deliver(d delivery) {
+	backoff := firstBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(d)
+		if err == nil {
+			logger.Debugf("webhook %s: delivered %s %s", s.opts.URL, d.ev.Type, d.ev.ID)
+			return
+		}
+		if !retry || attempt >= s.opts.MaxRetries {
+			logger.Warnf("webhook %s: giving up on %s event %s: %v", s.opts.URL, d.ev.Type, d.ev.ID, err)
+			return
+		}
+		logger.Debugf("webhook %s: %s event %s: %v (retry in %s)", s.opts.URL, d.ev.Type, d.ev.ID, err, backoff)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// post sends one attempt; retry tells whether a failure may succeed later.
+func (s *sender) post(d delivery) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.opts.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "abdal-gost-proxy-webhook")
+	req.Header.Set(HeaderEvent, d.ev.Type)
+	req.Header.Set(HeaderDelivery, d.ev.ID)
+	if s.opts.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(s.opts.Secret, d.body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, fmt.Errorf("HTTP %s", resp.Status)
+}
+
+// Sign returns the signature header value of body: "sha256=" + hex HMAC-SHA256 with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
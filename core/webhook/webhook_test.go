@@ -0,0 +1,153 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal Gost Proxy
+ * File Name : webhook_test.go
+ * Author : Ebrahim Shafiei (EbraSha)
+ * Email : Prof.Shafiei@Gmail.com
+ * Created On : 2026-10-20 04:21:46
+ * Description : Tests for webhook delivery: retries with exponential backoff and the HMAC signature header.
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// attempt is one request received by the test endpoint.
+type attempt struct {
+	at     time.Time
+	header http.Header
+	body   []byte
+}
+
+// endpoint answers with the next status of statuses (the last one repeats) and records every request.
+type endpoint struct {
+	statuses []int
+	attempts []attempt
+	mu       sync.Mutex
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	e.mu.Lock()
+	e.attempts = append(e.attempts, attempt{at: time.Now(), header: r.Header.Clone(), body: body})
+	status := e.statuses[len(e.statuses)-1]
+	if n := len(e.attempts); n <= len(e.statuses) {
+		status = e.statuses[n-1]
+	}
+	e.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (e *endpoint) received() []attempt {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]attempt(nil), e.attempts...)
+}
+
+// deliverOne sends one event to e through a sender with opts and waits until the sender is done with it.
+func deliverOne(t *testing.T, e *endpoint, opts Options) []attempt {
+	t.Helper()
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	opts.URL = srv.URL
+	s := newSender(opts)
+	ev := Event{ID: newID(), Type: "user.added", Time: time.Now().UTC(), Host: "test", Data: map[string]interface{}{"user": "a@x"}}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.enqueue(ev, body)
+	s.close()
+	return e.received()
+}
+
+func withBackoff(t *testing.T, d time.Duration) {
+	prev := firstBackoff
+	firstBackoff = d
+	t.Cleanup(func() { firstBackoff = prev })
+}
+
+func TestRetryBackoff(t *testing.T) {
+	withBackoff(t, 50*time.Millisecond)
+	e := &endpoint{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusNoContent}}
+	got := deliverOne(t, e, Options{MaxRetries: 5})
+	if len(got) != 4 {
+		t.Fatalf("%d attempts, want 4 (three retries, then delivered)", len(got))
+	}
+	// The waits double: 50ms, 100ms, 200ms.
+	for i := 1; i < len(got); i++ {
+		want := firstBackoff << (i - 1)
+		if gap := got[i].at.Sub(got[i-1].at); gap < want {
+			t.Errorf("retry %d after %s, want at least %s", i, gap, want)
+		}
+		if got[i].header.Get(HeaderDelivery) != got[0].header.Get(HeaderDelivery) || string(got[i].body) != string(got[0].body) {
+			t.Errorf("retry %d is not the same delivery", i)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	withBackoff(t, 10*time.Millisecond)
+	tests := []struct {
+		name     string
+		status   int
+		retries  int
+		attempts int
+	}{
+		{"server error retried up to max_retries", http.StatusInternalServerError, 2, 3},
+		{"client error not retried", http.StatusBadRequest, 5, 1},
+		{"zero retries", http.StatusInternalServerError, 0, 1},
+		{"negative means the default", http.StatusInternalServerError, -1, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := deliverOne(t, &endpoint{statuses: []int{tt.status}}, Options{MaxRetries: tt.retries})
+			if len(got) != tt.attempts {
+				t.Errorf("%d attempts, want %d", len(got), tt.attempts)
+			}
+		})
+	}
+}
+
+func TestSignatureHeader(t *testing.T) {
+	got := deliverOne(t, &endpoint{statuses: []int{http.StatusOK}}, Options{Secret: "s3cret"})
+	if len(got) != 1 {
+		t.Fatalf("%d attempts, want 1", len(got))
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(got[0].body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if sig := got[0].header.Get(HeaderSignature); sig != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, sig, want)
+	}
+	if ev := got[0].header.Get(HeaderEvent); ev != "user.added" {
+		t.Errorf("%s = %q", HeaderEvent, ev)
+	}
+	var ev Event
+	if err := json.Unmarshal(got[0].body, &ev); err != nil || ev.ID != got[0].header.Get(HeaderDelivery) {
+		t.Errorf("body %s does not match delivery %q", got[0].body, got[0].header.Get(HeaderDelivery))
+	}
+
+	unsigned := deliverOne(t, &endpoint{statuses: []int{http.StatusOK}}, Options{})
+	if len(unsigned) != 1 || unsigned[0].header.Get(HeaderSignature) != "" {
+		t.Errorf("request without a secret is signed")
+	}
+}
@@ -6,7 +6,7 @@
  * Author : Ebrahim Shafiei (EbraSha)
  * Email : Prof.Shafiei@Gmail.com
  * Created On : 2026-02-14 22:16:06
- * Description : Minimal server entry: loads config and runs core server.
+ * Description : Minimal server entry: loads config and runs core server (SIGHUP / file change reloads).
  * -------------------------------------------------------------------
  *
  * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
@@ -20,15 +20,21 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/ebrasha/abdal-gost-proxy/core/colors"
 	"github.com/ebrasha/abdal-gost-proxy/core/display"
+	"github.com/ebrasha/abdal-gost-proxy/core/logger"
 	"github.com/ebrasha/abdal-gost-proxy/core/models"
 	"github.com/ebrasha/abdal-gost-proxy/core/services/server"
 	"github.com/ebrasha/abdal-gost-proxy/core/term"
+	"github.com/ebrasha/abdal-gost-proxy/core/userstore"
+	"github.com/xtls/xray-core/common/uuid"
 )
 
 const defaultServerConfigPath = "abdal-gost-proxy-server.json"
@@ -36,21 +42,254 @@ const defaultServerConfigPath = "abdal-gost-proxy-server.json"
 func main() {
 	term.EnableANSI()
 	display.PrintBanner("Server")
+	args := os.Args[1:]
+	command := ""
+	if len(args) > 0 {
+		switch args[0] {
+		case "upgrade", "connections", "kick", "users":
+			command, args = args[0], args[1:]
+		}
+	}
+	commandArg, usersAction := "", ""
+	if command == "kick" {
+		if len(args) == 0 {
+			logger.Fatalf("usage: kick <user email> [config]")
+		}
+		commandArg, args = args[0], args[1:]
+	}
+	if command == "users" {
+		if len(args) == 0 {
+			logger.Fatalf("usage: users list|add|remove|reset|import|export [email|file] [config]")
+		}
+		usersAction, args = args[0], args[1:]
+		if usersAction != "list" {
+			if len(args) == 0 {
+				logger.Fatalf("usage: users %s <%s> [config]", usersAction, usersArgName(usersAction))
+			}
+			commandArg, args = args[0], args[1:]
+		}
+	}
 	cfgPath := defaultServerConfigPath
-	if len(os.Args) > 1 {
-		cfgPath = os.Args[1]
+	if len(args) > 0 {
+		cfgPath = args[0]
 	}
-	data, err := os.ReadFile(cfgPath)
+	cfg, err := server.LoadConfig(cfgPath)
 	if err != nil {
-		log.Fatalf("read config %s: %v", cfgPath, err)
+		logger.Fatalf("%v", err)
+	}
+	if err := logger.Setup(&cfg.Log, "server"); err != nil {
+		logger.Fatalf("%v", err)
 	}
-	var cfg models.ServerConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		log.Fatalf("parse config: %v", err)
+	defer logger.Close()
+	switch command {
+	case "upgrade":
+		// "upgrade [config]" asks the running server to hand its sockets to the (replaced) binary.
+		pid, err := server.SignalUpgrade(cfg)
+		if err != nil {
+			logger.Fatalf("upgrade: %v", err)
+		}
+		logger.Infof("upgrade requested from pid %d", pid)
+		return
+	case "connections":
+		// "connections [config]" lists the active sessions of the running server (needs control.listen).
+		rep, err := server.ListConnections(cfg, "")
+		if err != nil {
+			logger.Fatalf("connections: %v", err)
+		}
+		printConnections(rep)
+		return
+	case "kick":
+		// "kick <email> [config]" closes every open connection of a user.
+		n, err := server.KickUser(cfg, commandArg)
+		if err != nil {
+			logger.Fatalf("kick: %v", err)
+		}
+		logger.Infof("closed %d connections of %s", n, commandArg)
+		return
+	case "users":
+		// "users <action> [email|file] [config]" edits the user store; reload the server to apply.
+		if cfg.UserStore.Path == "" {
+			logger.Fatalf("users: user_store.path is not set in %s", cfgPath)
+		}
+		if err := usersCommand(cfg.UserStore.Path, usersAction, commandArg); err != nil {
+			logger.Fatalf("users %s: %v", usersAction, err)
+		}
+		return
 	}
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
-	if err := server.Run(ctx, &cfg); err != nil && ctx.Err() == nil {
-		log.Fatalf("server: %v", err)
+	srv, err := server.NewServer(cfg)
+	if err != nil {
+		logger.Fatalf("server: %v", err)
+	}
+	go handleReloads(ctx, srv, cfgPath, cfg.Reload.WatchFile, time.Duration(cfg.Reload.WatchIntervalSeconds)*time.Second)
+	go srv.HandleUpgrades(ctx, stop)
+	// Once shutdown starts, a second Ctrl+C skips the drain and exits immediately.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := srv.Run(ctx); err != nil && ctx.Err() == nil {
+		logger.Fatalf("server: %v", err)
+	}
+}
+
+// handleReloads re-reads the config on SIGHUP, when the auth backend user list changes and, when enabled, whenever the file changes.
+func handleReloads(ctx context.Context, srv *server.Server, cfgPath string, watch bool, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	if watch {
+		go server.WatchConfigFile(ctx, cfgPath, interval, notify)
+	}
+	go srv.SyncAuthBackend(ctx, notify)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-changed:
+		}
+		srv.ReloadFile(cfgPath)
+	}
+}
+
+// usersArgName names the argument of a users action for usage messages.
+func usersArgName(action string) string {
+	if action == "import" || action == "export" {
+		return "file"
+	}
+	return "email"
+}
+
+// usersCommand runs one users action against the store at path.
+func usersCommand(path, action, arg string) error {
+	return userstore.With(path, func(st *userstore.Store) error {
+		switch action {
+		case "list":
+			users, err := st.List()
+			if err != nil {
+				return err
+			}
+			printStoredUsers(users)
+		case "add":
+			id := uuid.New()
+			if err := st.Add(userstore.User{ServerUser: models.ServerUser{ID: id.String(), Email: arg}}); err != nil {
+				return err
+			}
+			logger.Infof("added %s with id %s (reload the server to apply)", arg, id.String())
+		case "remove":
+			ok, err := st.Delete(arg)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("no user %s", arg)
+			}
+			logger.Infof("removed %s (reload the server to apply)", arg)
+		case "reset":
+			ok, err := st.ResetTraffic(arg)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("no user %s", arg)
+			}
+			logger.Infof("reset the traffic counters of %s (reload the server to apply)", arg)
+		case "import":
+			data, err := os.ReadFile(arg)
+			if err != nil {
+				return err
+			}
+			users, err := userstore.ParseUsers(data)
+			if err != nil {
+				return err
+			}
+			added, updated, err := st.Put(users)
+			if err != nil {
+				return err
+			}
+			logger.Infof("imported %d users (%d added, %d updated; reload the server to apply)", len(users), added, updated)
+		case "export":
+			users, err := st.List()
+			if err != nil {
+				return err
+			}
+			data, err := json.MarshalIndent(users, "", "  ")
+			if err != nil {
+				return err
+			}
+			data = append(data, '\n')
+			if err := os.WriteFile(arg, data, 0600); err != nil {
+				return err
+			}
+			logger.Infof("exported %d users to %s", len(users), arg)
+		default:
+			return fmt.Errorf("unknown action (list, add, remove, reset, import or export)")
+		}
+		return nil
+	})
+}
+
+// printStoredUsers prints one line per stored user.
+func printStoredUsers(users []userstore.User) {
+	if len(users) == 0 {
+		fmt.Println(colors.Yellow("the user store is empty"))
+		return
+	}
+	for _, u := range users {
+		quota := "unlimited"
+		if u.QuotaGB > 0 {
+			quota = fmt.Sprintf("%g GB", u.QuotaGB)
+		}
+		expires := "never"
+		if u.ExpiresAt != "" {
+			expires = u.ExpiresAt
+		}
+		fmt.Printf("%s  %s  used %.3f GB of %s  expires %s\n", colors.Cyan(u.Email), u.ID,
+			float64(u.UpBytes+u.DownBytes)/1e9, quota, expires)
+		if u.Notes != "" {
+			fmt.Printf("    %s\n", u.Notes)
+		}
+	}
+}
+
+// printConnections prints the sessions of every connected user.
+func printConnections(rep *server.ConnectionsReport) {
+	if len(rep.Users) == 0 {
+		fmt.Println(colors.Yellow("no active connections"))
+		return
+	}
+	for _, u := range rep.Users {
+		fmt.Println(colors.Cyan(fmt.Sprintf("%s (%d connections)", u.User, u.Connections)))
+		for _, sess := range u.Sessions {
+			fmt.Printf("  %s  since %s (%s)  %d conns  up %s/s  down %s/s  total %s / %s\n",
+				colors.Magenta(sess.SourceIP), sess.Since.Local().Format("2006-01-02 15:04:05"),
+				time.Since(sess.Since).Round(time.Second), sess.Connections,
+				formatBytes(sess.UpBps), formatBytes(sess.DownBps),
+				formatBytes(float64(sess.UpBytes)), formatBytes(float64(sess.DownBytes)))
+			fmt.Printf("    -> %s\n", strings.Join(sess.Destinations, ", "))
+		}
+	}
+}
+
+// formatBytes renders a byte count with a binary unit (e.g. 1.5 KiB).
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
 	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
 }